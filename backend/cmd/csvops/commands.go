@@ -0,0 +1,947 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/csvops"
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+)
+
+// readJSONFile decodes the JSON document at path into v.
+func readJSONFile(path string, v interface{}) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return nil
+}
+
+func runCrossRef(args []string) error {
+	fs := newFlagSet("crossref", "LIST... MASTER")
+	var iof ioFlags
+	iof.register(fs)
+	var keys, listKeys stringList
+	fs.Var(&keys, "key", "master key column (header name or numeric index; repeat for a composite key)")
+	fs.Var(&listKeys, "list-key", "list key column when it differs from --key (repeat in --key order)")
+	match := fs.String("match", string(csvops.MatchExact), "match method: exact | case_insensitive | levenshtein | damerau | jaro_winkler | token_set | soundex | metaphone")
+	threshold := fs.Float64("threshold", 0, "fuzzy methods: minimum similarity 0..1 (default 0.85; phonetic: any)")
+	trim := fs.Bool("trim", false, "trim and collapse whitespace in keys before matching")
+	normalizers := fs.String("normalize", "", "comma-separated key normalizers applied in order: "+strings.Join(csvops.KeyNormalizerNames(), " | "))
+	ambiguous := fs.String("ambiguous", string(csvops.AmbiguousAccept), "list rows whose key is on several master rows: accept | mark | reject")
+	annotate := fs.Bool("annotate", false, "return every list row with a match_status column instead of only the matches")
+	masterRow := fs.Bool("master-row", false, "with --annotate, add a master_row column with the matching master row numbers")
+	stream := fs.Bool("stream", false, "stream the lists row by row (only the master is held in memory)")
+	streamDups := fs.Bool("stream-duplicates", false, "with --stream, also report keys on several list rows (keeps every list key in memory)")
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) < 2 {
+		fs.Usage()
+		return errors.New("need at least one list file followed by the master file")
+	}
+
+	master, err := iof.readTable(files[len(files)-1])
+	if err != nil {
+		return err
+	}
+	req := csvops.CrossRefMultiRequest{
+		Operation: "crossref",
+		Options: csvops.CrossRefMultiOptions{
+			MatchMethod:      csvops.MatchMethod(*match),
+			MasterKeys:       keys,
+			DefaultListKeys:  listKeys,
+			TrimSpaces:       *trim,
+			Threshold:        *threshold,
+			Normalizers:      keyNormalizers(*normalizers),
+			Ambiguous:        csvops.AmbiguousPolicy(*ambiguous),
+			Annotate:         *annotate,
+			MasterRow:        *masterRow,
+			StreamDuplicates: *streamDups,
+		},
+		Datasets: types.MultiDatasets{Master: master},
+	}
+	suffix := "_matched"
+	if *annotate {
+		suffix = "_annotated"
+	}
+
+	if *stream {
+		return iof.runStreamed(files[:len(files)-1], suffix, func(lists []csvops.StreamTable) (failed []string, err error) {
+			if req.Options.MatchMethod.IsFuzzy() {
+				scores, serr := iof.createScoreOutputs(lists)
+				defer func() {
+					if cerr := closeScoreOutputs(lists, scores, failed, err); err == nil {
+						err = cerr
+					}
+				}()
+				if serr != nil {
+					return nil, serr
+				}
+			}
+			resp, err := csvops.CrossRefMultiStream(req, lists)
+			if err != nil {
+				return nil, err
+			}
+			if err := iof.writeDuplicates("master", resp.MasterDuplicates); err != nil {
+				return nil, err
+			}
+			for _, pl := range resp.PerList {
+				if reportListError(pl.Name, pl.Error) {
+					failed = append(failed, pl.Name)
+					continue
+				}
+				fmt.Printf("%s: processed %d, matched %d, missing %d, ambiguous %d\n", pl.Name, pl.Processed, pl.Matched, pl.Missing, pl.Ambiguous)
+				if err := iof.writeDuplicates(pl.Name, pl.Duplicates); err != nil {
+					return failed, err
+				}
+			}
+			return failed, nil
+		})
+	}
+
+	lists, err := iof.readNamedTables(files[:len(files)-1])
+	if err != nil {
+		return err
+	}
+	req.Datasets.Lists = lists
+	resp, err := csvops.CrossRefMulti(req)
+	if err != nil {
+		return err
+	}
+	if err := iof.writeDuplicates("master", resp.MasterDuplicates); err != nil {
+		return err
+	}
+	for _, pl := range resp.PerList {
+		if reportListError(pl.Name, pl.Error) {
+			continue
+		}
+		fmt.Printf("%s: processed %d, matched %d, missing %d, ambiguous %d\n", pl.Name, pl.Processed, pl.Matched, pl.Missing, pl.Ambiguous)
+		if err := iof.writeResult(pl.Name+suffix, pl.Result); err != nil {
+			return err
+		}
+		if err := iof.writeMatchScores(pl.Name, pl.Matches); err != nil {
+			return err
+		}
+		if err := iof.writeDuplicates(pl.Name, pl.Duplicates); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDuplicates writes the keys found on several rows of a table to
+// <name>_duplicates.csv, with the row numbers separated by spaces.
+func (f *ioFlags) writeDuplicates(name string, dups []csvops.DuplicateKey) error {
+	if len(dups) == 0 {
+		return nil
+	}
+	tbl := types.TableData{
+		HasHeader: true,
+		Header:    []string{"key", "count", "rows"},
+		Rows:      make([][]string, 0, len(dups)),
+	}
+	for _, d := range dups {
+		rows := make([]string, len(d.Rows))
+		for i, r := range d.Rows {
+			rows[i] = strconv.Itoa(r)
+		}
+		tbl.Rows = append(tbl.Rows, []string{d.Key, strconv.Itoa(d.Count), strings.Join(rows, " ")})
+	}
+	return f.writeResult(name+"_duplicates", tbl)
+}
+
+// writeChanges writes the change log of a dry run to <name>_changes.csv, with the
+// causes of each change separated by commas.
+func (f *ioFlags) writeChanges(name string, changes []csvops.CellChange) error {
+	tbl := types.TableData{
+		HasHeader: true,
+		Header:    []string{"row", "column", "old", "new", "causes"},
+		Rows:      make([][]string, 0, len(changes)),
+	}
+	for _, c := range changes {
+		tbl.Rows = append(tbl.Rows, []string{strconv.Itoa(c.Row), c.Column, c.Old, c.New, strings.Join(c.Causes, ",")})
+	}
+	return f.writeResult(name+"_changes", tbl)
+}
+
+// writeMatchScores writes the best fuzzy candidate per list row to <list>_scores.csv.
+func (f *ioFlags) writeMatchScores(name string, matches []csvops.FuzzyMatch) error {
+	if len(matches) == 0 {
+		return nil
+	}
+	tbl := types.TableData{
+		HasHeader: true,
+		Header:    csvops.MatchScoresHeader,
+		Rows:      make([][]string, 0, len(matches)),
+	}
+	for _, m := range matches {
+		tbl.Rows = append(tbl.Rows, m.Record())
+	}
+	return f.writeResult(name+"_scores", tbl)
+}
+
+// createScoreOutputs opens <list>_scores.csv for every streamed list and sets it as
+// the list's Scores writer, so fuzzy scores are written as the lists are read.
+func (f *ioFlags) createScoreOutputs(lists []csvops.StreamTable) ([]*outputFile, error) {
+	outs := make([]*outputFile, 0, len(lists))
+	for i := range lists {
+		out, err := f.createOutput(lists[i].Name + "_scores")
+		if err != nil {
+			return outs, err
+		}
+		outs = append(outs, out)
+		lists[i].Scores = out
+	}
+	return outs, nil
+}
+
+// closeScoreOutputs closes the outputs createScoreOutputs opened for lists,
+// removing those of failed lists (every one when err is set).
+func closeScoreOutputs(lists []csvops.StreamTable, outs []*outputFile, failed []string, err error) error {
+	discard := map[string]bool{}
+	for _, name := range failed {
+		discard[name] = true
+	}
+	var first error
+	for i, out := range outs {
+		if cerr := out.close(err != nil || discard[lists[i].Name]); cerr != nil && first == nil {
+			first = cerr
+		}
+	}
+	return first
+}
+
+func runJoin(args []string) error {
+	fs := newFlagSet("join", "LIST... MASTER")
+	var iof ioFlags
+	iof.register(fs)
+	var keys, listKeys, columns stringList
+	fs.Var(&keys, "key", "master key column (header name or numeric index; repeat for a composite key)")
+	fs.Var(&listKeys, "list-key", "list key column when it differs from --key (repeat in --key order)")
+	fs.Var(&columns, "column", "master column to append (repeatable; default all but the key columns)")
+	joinType := fs.String("type", string(csvops.JoinLeft), "join type: inner | left | right | full")
+	duplicates := fs.String("duplicates", string(csvops.DuplicatesFirst), "master rows sharing a key: first | last | all | error")
+	suffix := fs.String("suffix", csvops.DefaultJoinSuffix, "appended to master column names that clash with list columns")
+	match := fs.String("match", string(csvops.MatchExact), "match method: exact | case_insensitive | levenshtein | damerau | jaro_winkler | token_set | soundex | metaphone")
+	threshold := fs.Float64("threshold", 0, "fuzzy methods: minimum similarity 0..1 (default 0.85; phonetic: any)")
+	trim := fs.Bool("trim", false, "trim and collapse whitespace in keys before matching")
+	normalizers := fs.String("normalize", "", "comma-separated key normalizers applied in order: "+strings.Join(csvops.KeyNormalizerNames(), " | "))
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) < 2 {
+		fs.Usage()
+		return errors.New("need at least one list file followed by the master file")
+	}
+
+	master, err := iof.readTable(files[len(files)-1])
+	if err != nil {
+		return err
+	}
+	lists, err := iof.readNamedTables(files[:len(files)-1])
+	if err != nil {
+		return err
+	}
+	req := csvops.JoinRequest{
+		Operation: "join",
+		Options: csvops.JoinOptions{
+			CrossRefMultiOptions: csvops.CrossRefMultiOptions{
+				MatchMethod:     csvops.MatchMethod(*match),
+				MasterKeys:      keys,
+				DefaultListKeys: listKeys,
+				TrimSpaces:      *trim,
+				Threshold:       *threshold,
+				Normalizers:     keyNormalizers(*normalizers),
+			},
+			Type:       csvops.JoinType(*joinType),
+			Columns:    columns,
+			Suffix:     *suffix,
+			Duplicates: csvops.DuplicatePolicy(*duplicates),
+		},
+		Datasets: types.MultiDatasets{Master: master, Lists: lists},
+	}
+	resp, err := csvops.Join(req)
+	if err != nil {
+		return err
+	}
+	for _, pl := range resp.PerList {
+		if reportListError(pl.Name, pl.Error) {
+			continue
+		}
+		fmt.Printf("%s: processed %d, matched %d, missing %d, duplicated %d, master only %d\n", pl.Name, pl.Processed, pl.Matched, pl.Missing, pl.Duplicated, pl.MasterOnly)
+		if err := iof.writeResult(pl.Name+"_joined", pl.Result); err != nil {
+			return err
+		}
+		if err := iof.writeMatchScores(pl.Name, pl.Matches); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runClean(args []string) error {
+	fs := newFlagSet("clean", "FILE...")
+	var iof ioFlags
+	iof.register(fs)
+	trim := fs.Bool("trim", false, "trim leading/trailing whitespace")
+	collapse := fs.Bool("collapse-ws", false, "collapse runs of internal whitespace to a single space")
+	caseMode := fs.String("case", string(csvops.CaseNone), "case standardisation: none | upper | lower | title")
+	columns := fs.String("columns", "", "comma-separated columns to clean (default all)")
+	caseInsensitive := fs.Bool("case-insensitive", false, "resolve --columns case-insensitively")
+	stream := fs.Bool("stream", false, "process the files row by row instead of loading them")
+	dryRun := fs.Bool("dry-run", false, "write the cells that would change to <file>_changes.csv instead of cleaning")
+	changedRows := fs.Bool("changed-rows", false, "with --dry-run: also write the changed rows, cleaned, to <file>_clean.csv")
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		fs.Usage()
+		return errors.New("need at least one input file")
+	}
+
+	req := csvops.DataCleanRequest{
+		Operation: "data_clean",
+		Options: csvops.DataCleanOptions{
+			TrimSpaces:      *trim,
+			CollapseInnerWS: *collapse,
+			CaseMode:        csvops.CaseMode(*caseMode),
+			Columns:         splitList(*columns),
+			CaseInsensitive: *caseInsensitive,
+			DryRun:          *dryRun,
+			ChangedRows:     *changedRows,
+		},
+	}
+
+	if *stream {
+		return iof.runStreamed(files, "_clean", func(tables []csvops.StreamTable) ([]string, error) {
+			resp, err := csvops.DataCleanStream(req, tables)
+			if err != nil {
+				return nil, err
+			}
+			var discard []string
+			for _, pl := range resp.PerList {
+				if reportListError(pl.Name, pl.Error) {
+					discard = append(discard, pl.Name)
+					continue
+				}
+				fmt.Printf("%s: processed %d, modified %d cells\n", pl.Name, pl.Processed, pl.Modified)
+				if *dryRun {
+					if err := iof.writeChanges(pl.Name, pl.Changes); err != nil {
+						return nil, err
+					}
+					if !*changedRows {
+						discard = append(discard, pl.Name)
+					}
+				}
+			}
+			return discard, nil
+		})
+	}
+
+	tables, err := iof.readNamedTables(files)
+	if err != nil {
+		return err
+	}
+	req.Datasets = types.MultiDatasets{Lists: tables}
+	resp, err := csvops.DataClean(req)
+	if err != nil {
+		return err
+	}
+	for _, pl := range resp.PerList {
+		if reportListError(pl.Name, pl.Error) {
+			continue
+		}
+		fmt.Printf("%s: processed %d, modified %d cells\n", pl.Name, pl.Processed, pl.Modified)
+		if *dryRun {
+			if err := iof.writeChanges(pl.Name, pl.Changes); err != nil {
+				return err
+			}
+			if !*changedRows {
+				continue
+			}
+		}
+		if err := iof.writeResult(pl.Name+"_clean", pl.Result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runSort(args []string) error {
+	fs := newFlagSet("sort", "FILE...")
+	var iof ioFlags
+	iof.register(fs)
+	key := fs.String("key", "", "column to sort by (header name or numeric index)")
+	mode := fs.String("mode", string(csvops.SortAlpha), "sort mode: alphabetical | numeric | date | natural | version | ip | locale")
+	order := fs.String("order", string(csvops.OrderAsc), "sort order: asc | desc")
+	trim := fs.Bool("trim", false, "trim values before comparing")
+	caseInsensitive := fs.Bool("case-insensitive", false, "ignore case in alphabetical mode")
+	dateFormat := fs.String("date-format", "", "explicit Go time layout for date mode")
+	locale := fs.String("locale", "", "collation locale for locale mode (e.g. de, fr, sv; default: root order)")
+	nulls := fs.String("nulls", "", "empty/unparsable values: first | last | drop | error (default: last asc, first desc)")
+	var by stringList
+	fs.Var(&by, "by", "sort level as Column[:mode[:order[:nulls]]] (repeatable, highest priority first; replaces --key)")
+	stream := fs.Bool("stream", false, "external merge sort: spill sorted runs to disk instead of loading the files")
+	memoryMB := fs.Int("memory-mb", 0, "with --stream, megabytes of rows to hold before spilling a run (default 64)")
+	tempDir := fs.String("temp-dir", "", "with --stream, directory for temporary run files (default system temp)")
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		fs.Usage()
+		return errors.New("need at least one input file")
+	}
+
+	req := csvops.AdvancedSortRequest{
+		Operation: "advanced_sort",
+		Options: csvops.AdvancedSortOptions{
+			Mode:            csvops.SortMode(*mode),
+			Order:           csvops.SortOrder(*order),
+			Key:             *key,
+			TrimSpaces:      *trim,
+			CaseInsensitive: *caseInsensitive,
+			DateFormat:      *dateFormat,
+			Nulls:           csvops.NullPlacement(*nulls),
+			Locale:          *locale,
+			MemoryBudgetMB:  *memoryMB,
+			TempDir:         *tempDir,
+		},
+	}
+	for _, b := range by {
+		req.Options.Keys = append(req.Options.Keys, parseSortKey(b))
+	}
+
+	if *stream {
+		return iof.runStreamed(files, "_sorted", func(tables []csvops.StreamTable) ([]string, error) {
+			resp, err := csvops.AdvancedSortStream(req, tables)
+			if err != nil {
+				return nil, err
+			}
+			var failed []string
+			for _, pl := range resp.PerList {
+				printInvalidValues(pl)
+				if reportListError(pl.Name, pl.Error) {
+					failed = append(failed, pl.Name)
+				}
+			}
+			return failed, nil
+		})
+	}
+
+	tables, err := iof.readNamedTables(files)
+	if err != nil {
+		return err
+	}
+	req.Datasets = types.MultiDatasets{Lists: tables}
+	resp, err := csvops.AdvancedSort(req)
+	if err != nil {
+		return err
+	}
+	for _, pl := range resp.PerList {
+		printInvalidValues(pl)
+		if reportListError(pl.Name, pl.Error) {
+			continue
+		}
+		if err := iof.writeResult(pl.Name+"_sorted", pl.Result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printInvalidValues reports the cells of a sorted table that did not parse.
+func printInvalidValues(pl csvops.PerSortResult) {
+	if pl.InvalidCount == 0 && pl.Dropped == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s: %d invalid values, %d rows dropped\n", pl.Name, pl.InvalidCount, pl.Dropped)
+	const shown = 10
+	for i, iv := range pl.Invalid {
+		if i == shown {
+			fmt.Fprintf(os.Stderr, "  ... and %d more\n", pl.InvalidCount-shown)
+			break
+		}
+		fmt.Fprintf(os.Stderr, "  row %d, %s: %q\n", iv.Row, iv.Column, iv.Value)
+	}
+}
+
+// parseSortKey turns "Column[:mode[:order[:nulls]]]" into a SortKey.
+func parseSortKey(s string) csvops.SortKey {
+	parts := strings.Split(s, ":")
+	k := csvops.SortKey{Key: strings.TrimSpace(parts[0])}
+	if len(parts) > 1 {
+		k.Mode = csvops.SortMode(strings.TrimSpace(parts[1]))
+	}
+	if len(parts) > 2 {
+		k.Order = csvops.SortOrder(strings.TrimSpace(parts[2]))
+	}
+	if len(parts) > 3 {
+		k.Nulls = csvops.NullPlacement(strings.TrimSpace(parts[3]))
+	}
+	return k
+}
+
+// parseWhere turns "Column:operator:value" into a Condition.
+func parseWhere(s string) (csvops.Condition, error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) < 2 {
+		return csvops.Condition{}, fmt.Errorf("invalid --where %q: expected Column:operator[:value]", s)
+	}
+	cond := csvops.Condition{
+		Column:   strings.TrimSpace(parts[0]),
+		Operator: csvops.ConditionOperator(strings.TrimSpace(parts[1])),
+	}
+	if len(parts) == 3 {
+		cond.Value = parts[2]
+	}
+	return cond, nil
+}
+
+// conditionGroup builds the filter of the --where, --any and --filter flags.
+func conditionGroup(where []string, anyOf bool, filterFile string) (csvops.ConditionGroup, error) {
+	filter := csvops.ConditionGroup{Op: "and"}
+	if anyOf {
+		filter.Op = "or"
+	}
+	if filterFile != "" {
+		if err := readJSONFile(filterFile, &filter); err != nil {
+			return filter, err
+		}
+		return filter, nil
+	}
+	for _, w := range where {
+		cond, err := parseWhere(w)
+		if err != nil {
+			return filter, err
+		}
+		filter.Conds = append(filter.Conds, cond)
+	}
+	return filter, nil
+}
+
+func runExtract(args []string) error {
+	fs := newFlagSet("extract", "FILE")
+	var iof ioFlags
+	iof.register(fs)
+	var where stringList
+	fs.Var(&where, "where", "condition as Column:operator:value (repeatable), e.g. Region:equals:EMEA")
+	anyOf := fs.Bool("any", false, "combine --where conditions with OR instead of AND")
+	filterFile := fs.String("filter", "", "JSON file holding a full condition group (overrides --where)")
+	trim := fs.Bool("trim", false, "trim values before comparing")
+	caseInsensitive := fs.Bool("case-insensitive", false, "compare strings case-insensitively")
+	dateFormat := fs.String("date-format", "", "explicit Go time layout for date comparisons")
+	limit := fs.Int("limit", 0, "maximum rows to return (0 = all)")
+	offset := fs.Int("offset", 0, "matching rows to skip")
+	stream := fs.Bool("stream", false, "process the file row by row instead of loading it")
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) != 1 {
+		fs.Usage()
+		return errors.New("need exactly one input file")
+	}
+
+	filter, err := conditionGroup(where, *anyOf, *filterFile)
+	if err != nil {
+		return err
+	}
+
+	req := csvops.AdvancedExtractRequest{
+		Operation: "advanced_extract",
+		Options: csvops.AdvancedExtractOptions{
+			TrimSpaces:      *trim,
+			CaseInsensitive: *caseInsensitive,
+			DateFormat:      *dateFormat,
+		},
+		Filter:     filter,
+		Pagination: csvops.PaginationOptions{Limit: *limit, Offset: *offset},
+	}
+
+	if *stream {
+		return iof.runStreamed(files, "_extract", func(tables []csvops.StreamTable) ([]string, error) {
+			resp, err := csvops.AdvancedExtractStream(req, tables[0].Input, tables[0].Output)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Printf("%s: processed %d, matched %d\n", tables[0].Name, resp.Summary.Processed, resp.Summary.Matched)
+			return nil, nil
+		})
+	}
+
+	tbl, err := iof.readTable(files[0])
+	if err != nil {
+		return err
+	}
+	req.Dataset = tbl
+	resp, err := csvops.AdvancedExtract(req)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: processed %d, matched %d\n", tableName(files[0]), resp.Summary.Processed, resp.Summary.Matched)
+	return iof.writeResult(tableName(files[0])+"_extract", resp.Result)
+}
+
+func runReplace(args []string) error {
+	fs := newFlagSet("replace", "FILE")
+	var iof ioFlags
+	iof.register(fs)
+	var find stringList
+	fs.Var(&find, "find", "value to replace (repeatable)")
+	with := fs.String("with", "", "replacement for every --find value")
+	wholeCell := fs.Bool("whole-cell", false, "only replace cells that equal a --find value")
+	mode := fs.String("mode", string(csvops.ReplaceLiteral), "how --find values match: literal | regex (--with may use $1, ${name}) | glob (* and ?)")
+	rulesFile := fs.String("rules", "", "JSON file holding an array of replace rules (added after --find)")
+	mappingFile := fs.String("mapping", "", "CSV file of from/to replacements, expanded into rules after --rules")
+	mappingFrom := fs.String("mapping-from", "0", "--mapping column holding the values to replace")
+	mappingTo := fs.String("mapping-to", "1", "--mapping column holding the replacements")
+	mappingCase := fs.String("mapping-case", "", "optional --mapping column with a per-row case-insensitive flag (true/false)")
+	mappingWhole := fs.String("mapping-whole-cell", "", "optional --mapping column with a per-row whole-cell flag (true/false)")
+	strictMapping := fs.Bool("strict-mapping", false, "fail on conflicting, cyclic or chained --mapping rows instead of skipping or keeping them")
+	columns := fs.String("columns", "", "comma-separated columns to apply to (default all)")
+	trim := fs.Bool("trim", false, "match against trimmed cells; their whitespace is kept")
+	trimOutput := fs.Bool("trim-output", false, "trim every --columns cell written, changed or not")
+	caseInsensitive := fs.Bool("case-insensitive", false, "match case-insensitively unless a rule says otherwise")
+	stream := fs.Bool("stream", false, "process the file row by row instead of loading it")
+	var where stringList
+	fs.Var(&where, "where", "only replace in rows matching Column:operator:value (repeatable), e.g. Region:equals:EMEA")
+	anyOf := fs.Bool("any", false, "combine --where conditions with OR instead of AND")
+	filterFile := fs.String("filter", "", "JSON file holding a full condition group for the rows to replace in (overrides --where)")
+	whereTrim := fs.Bool("where-trim", false, "trim values before comparing them with --where conditions")
+	whereCase := fs.Bool("where-case-insensitive", false, "compare --where strings case-insensitively")
+	dateFormat := fs.String("date-format", "", "explicit Go time layout for date conditions")
+	dryRun := fs.Bool("dry-run", false, "write the cells that would change to <file>_changes.csv instead of replacing")
+	changedRows := fs.Bool("changed-rows", false, "with --dry-run: also write the changed rows, rewritten, to <file>_replaced.csv")
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) != 1 {
+		fs.Usage()
+		return errors.New("need exactly one input file")
+	}
+
+	var rules []csvops.ReplaceRule
+	if len(find) > 0 {
+		wc := *wholeCell
+		rules = append(rules, csvops.ReplaceRule{Targets: find, Replacement: *with, Mode: csvops.ReplaceMode(*mode), WholeCell: &wc})
+	}
+	if *rulesFile != "" {
+		var fileRules []csvops.ReplaceRule
+		if err := readJSONFile(*rulesFile, &fileRules); err != nil {
+			return err
+		}
+		rules = append(rules, fileRules...)
+	}
+
+	var rowFilter *csvops.ConditionGroup
+	if len(where) > 0 || *filterFile != "" {
+		filter, err := conditionGroup(where, *anyOf, *filterFile)
+		if err != nil {
+			return err
+		}
+		rowFilter = &filter
+	}
+
+	var mapping *csvops.ReplaceMapping
+	if *mappingFile != "" {
+		tbl, err := iof.readTable(*mappingFile)
+		if err != nil {
+			return err
+		}
+		mapping = &csvops.ReplaceMapping{
+			Table:           tbl,
+			From:            *mappingFrom,
+			To:              *mappingTo,
+			Mode:            csvops.ReplaceMode(*mode),
+			CaseInsensitive: *mappingCase,
+			WholeCell:       *mappingWhole,
+			Strict:          *strictMapping,
+		}
+	}
+
+	req := csvops.FindReplaceRequest{
+		Operation: "find_replace",
+		Options: csvops.FindReplaceOptions{
+			TrimSpaces:      *trim,
+			TrimOutput:      *trimOutput,
+			CaseInsensitive: *caseInsensitive,
+			Columns:         splitList(*columns),
+			DryRun:          *dryRun,
+			ChangedRows:     *changedRows,
+			WhereOptions: csvops.AdvancedExtractOptions{
+				TrimSpaces:      *whereTrim,
+				CaseInsensitive: *whereCase,
+				DateFormat:      *dateFormat,
+			},
+		},
+		Rules:   rules,
+		Mapping: mapping,
+		Where:   rowFilter,
+	}
+
+	if *stream {
+		return iof.runStreamed(files, "_replaced", func(tables []csvops.StreamTable) ([]string, error) {
+			resp, err := csvops.FindAndReplaceStream(req, tables[0].Input, tables[0].Output)
+			printMappingIssues(resp.Mapping)
+			printRuleResults(resp.PerRule)
+			if err != nil {
+				return nil, err
+			}
+			if !*dryRun {
+				return nil, nil
+			}
+			if err := iof.writeChanges(tables[0].Name, resp.Changes); err != nil {
+				return nil, err
+			}
+			if !*changedRows {
+				return []string{tables[0].Name}, nil
+			}
+			return nil, nil
+		})
+	}
+
+	tbl, err := iof.readTable(files[0])
+	if err != nil {
+		return err
+	}
+	req.Dataset = tbl
+	resp, err := csvops.FindAndReplace(req)
+	printMappingIssues(resp.Mapping)
+	printRuleResults(resp.PerRule)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		if err := iof.writeChanges(tableName(files[0]), resp.Changes); err != nil {
+			return err
+		}
+		if !*changedRows {
+			return nil
+		}
+	}
+	return iof.writeResult(tableName(files[0])+"_replaced", resp.Result)
+}
+
+// printMappingIssues reports the conflicts, cycles and chains of a --mapping file.
+func printMappingIssues(issues []csvops.MappingIssue) {
+	for _, is := range issues {
+		pairs := make([]string, len(is.Sources))
+		for i := range pairs {
+			pairs[i] = is.Sources[i] + " -> " + is.Targets[i]
+		}
+		if is.Kind == csvops.MappingConflict {
+			pairs = []string{is.Sources[0] + " -> " + strings.Join(is.Targets, " | ")}
+		}
+		fmt.Fprintf(os.Stderr, "mapping %s (rows %s): %s\n", is.Kind, strings.Trim(fmt.Sprint(is.Rows), "[]"), strings.Join(pairs, ", "))
+	}
+}
+
+func printRuleResults(perRule []csvops.FindReplaceRuleResult) {
+	for _, pr := range perRule {
+		if reportListError(fmt.Sprintf("rule %d", pr.Index), pr.Error) {
+			continue
+		}
+		fmt.Printf("rule %d: %d replacements\n", pr.Index, pr.Replacements)
+	}
+}
+
+func runOneToMany(args []string) error {
+	fs := newFlagSet("one-to-many", "MASTER LIST...")
+	var iof ioFlags
+	iof.register(fs)
+	var keys, values, listKeys stringList
+	fs.Var(&keys, "key", "key column to search (header name or numeric index; repeat for a composite key)")
+	fs.Var(&values, "value", "key value to look up (repeat once per --key)")
+	fs.Var(&listKeys, "list-key", "key column in the lists when it differs from --key (repeat in --key order)")
+	var lf lookupFlags
+	lf.register(fs)
+	var columnMap stringList
+	fs.Var(&columnMap, "map", "combined output: list column to master column as LISTCOL=MASTERCOL (repeatable), e.g. hostname=DeviceName")
+	union := fs.Bool("union", false, "combined output: keep list columns with no master column as extra columns")
+	match := fs.String("match", string(csvops.MatchExact), "match method: exact | case_insensitive")
+	trim := fs.Bool("trim", false, "trim and collapse whitespace before matching")
+	normalizers := fs.String("normalize", "", "comma-separated key normalizers applied in order: "+strings.Join(csvops.KeyNormalizerNames(), " | "))
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		fs.Usage()
+		return errors.New("need a master file and optional list files")
+	}
+
+	master, err := iof.readTable(files[0])
+	if err != nil {
+		return err
+	}
+	lists, err := iof.readNamedTables(files[1:])
+	if err != nil {
+		return err
+	}
+	mapping := map[string]string{}
+	for _, m := range columnMap {
+		from, to, ok := strings.Cut(m, "=")
+		if !ok {
+			return fmt.Errorf("invalid --map %q (expected LISTCOL=MASTERCOL)", m)
+		}
+		mapping[strings.TrimSpace(from)] = strings.TrimSpace(to)
+	}
+	for i := range lists {
+		lists[i].ListKeys = listKeys
+		lists[i].ColumnMap = mapping
+	}
+	target := csvops.OneToManyTarget{Keys: keys, Values: values}
+	if lf.batch() {
+		target = csvops.OneToManyTarget{Keys: keys, Lookup: lf.values}
+		if target.LookupFrom, err = lf.source(&iof); err != nil {
+			return err
+		}
+	}
+
+	resp, err := csvops.OneToMany(csvops.OneToManyRequest{
+		Operation: "one_to_many",
+		Options: csvops.OneToManyOptions{
+			MatchMethod: csvops.MatchMethod(*match),
+			TrimSpaces:  *trim,
+			Normalizers: keyNormalizers(*normalizers),
+			UnionSchema: *union,
+		},
+		Target:   target,
+		Datasets: types.MultiDatasets{Master: master, Lists: lists},
+	})
+	if err != nil {
+		return err
+	}
+	for _, pl := range resp.PerList {
+		if reportListError(pl.Name, pl.Error) {
+			continue
+		}
+		fmt.Printf("%s: processed %d, matched %d\n", pl.Name, pl.Processed, pl.Matched)
+		if err := iof.writeResult(pl.Name+"_matches", pl.Result); err != nil {
+			return err
+		}
+	}
+	if err := iof.writeLookupHits(resp.PerValue, resp.NoHits); err != nil {
+		return err
+	}
+	return iof.writeResult("combined", resp.Combined)
+}
+
+func runManyToOne(args []string) error {
+	fs := newFlagSet("many-to-one", "FILE")
+	var iof ioFlags
+	iof.register(fs)
+	oneKey := fs.String("one-key", "", "column holding the 'one' side, e.g. User")
+	manyKey := fs.String("many-key", "", "column holding the 'many' side, e.g. Device")
+	value := fs.String("value", "", "one-side value to look up")
+	groupAll := fs.Bool("group-all", false, "group the many values of every one-side value (--value not needed)")
+	var lf lookupFlags
+	lf.register(fs)
+	match := fs.String("match", string(csvops.MatchExact), "match method: exact | case_insensitive")
+	trim := fs.Bool("trim", false, "trim and collapse whitespace before matching")
+	normalizers := fs.String("normalize", "", "comma-separated key normalizers applied in order: "+strings.Join(csvops.KeyNormalizerNames(), " | "))
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) != 1 {
+		fs.Usage()
+		return errors.New("need exactly one input file")
+	}
+
+	tbl, err := iof.readTable(files[0])
+	if err != nil {
+		return err
+	}
+	target := csvops.ManyToOneTarget{OneKey: *oneKey, ManyKey: *manyKey, Value: *value}
+	if lf.batch() {
+		target.Value, target.Lookup = "", lf.values
+		if target.LookupFrom, err = lf.source(&iof); err != nil {
+			return err
+		}
+	}
+	resp, err := csvops.ManyToOne(csvops.ManyToOneRequest{
+		Operation: "many_to_one",
+		Options: csvops.ManyToOneOptions{
+			MatchMethod: csvops.MatchMethod(*match),
+			TrimSpaces:  *trim,
+			Normalizers: keyNormalizers(*normalizers),
+			GroupAll:    *groupAll,
+		},
+		Target:  target,
+		Dataset: tbl,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: processed %d, matched %d, groups %d\n", tableName(files[0]), resp.Summary.Processed, resp.Summary.Matched, len(resp.Groups))
+	if err := iof.writeResult(tableName(files[0])+"_matches", *resp.Matched); err != nil {
+		return err
+	}
+	if err := iof.writeLookupHits(resp.PerValue, resp.NoHits); err != nil {
+		return err
+	}
+	return iof.writeResult(tableName(files[0])+"_many", *resp.Aggregated)
+}
+
+func runRequest(args []string) error {
+	fs := newFlagSet("run", "REQUEST.json")
+	out := fs.String("out", "", "file to write the response JSON to (default stdout)")
+	compact := fs.Bool("compact", false, "emit compact JSON instead of indented")
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) != 1 {
+		fs.Usage()
+		return errors.New("need exactly one request file ('-' reads stdin)")
+	}
+
+	var raw []byte
+	if files[0] == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(files[0])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read request: %w", err)
+	}
+
+	resp, opErr := csvops.Dispatch(raw)
+	if resp == nil {
+		return opErr
+	}
+	if !*compact {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, resp, "", "  "); err != nil {
+			return err
+		}
+		resp = buf.Bytes()
+	}
+	resp = append(resp, '\n')
+
+	if *out == "" {
+		_, err = os.Stdout.Write(resp)
+	} else {
+		err = os.WriteFile(*out, resp, 0o644)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+	return opErr
+}
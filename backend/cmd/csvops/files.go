@@ -0,0 +1,321 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/csvio"
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/csvops"
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+)
+
+// ioFlags holds the flags shared by every command that reads and writes CSV files.
+type ioFlags struct {
+	out         string
+	outEncoding string
+	encoding    string
+	header      string
+	delimiter   string
+	comment     string
+	lazyQuotes  bool
+	ragged      string
+	maxRows     int
+}
+
+func (f *ioFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.out, "out", "output", "directory to write result CSVs to")
+	fs.StringVar(&f.outEncoding, "out-encoding", string(csvio.EncodingUTF8), "output encoding: utf-8 | utf-8-bom | utf-16le | utf-16be | windows-1252 | iso-8859-1")
+	f.registerInput(fs)
+}
+
+// registerInput registers only the flags that control how inputs are parsed.
+func (f *ioFlags) registerInput(fs *flag.FlagSet) {
+	fs.StringVar(&f.header, "header", string(csvio.HeaderPresent), "first row is a header: true | false | auto (keys must be numeric indices without one)")
+	fs.StringVar(&f.delimiter, "delimiter", "", "input field separator: a character or comma|tab|pipe|semicolon (default from file extension)")
+	fs.StringVar(&f.comment, "comment", "", "skip input lines starting with this character")
+	fs.BoolVar(&f.lazyQuotes, "lazy-quotes", false, "tolerate stray quotes in input fields")
+	fs.StringVar(&f.ragged, "ragged", string(csvio.RaggedKeep), "rows with a different field count: keep | pad | truncate | error")
+	fs.IntVar(&f.maxRows, "max-rows", 0, "read at most this many data rows per input (0 = all)")
+	fs.StringVar(&f.encoding, "encoding", string(csvio.EncodingAuto), "input encoding: auto | utf-8 | utf-16le | utf-16be | windows-1252 | iso-8859-1")
+}
+
+// readOptions maps the flags onto csvio.ReadOptions.
+func (f *ioFlags) readOptions() (csvio.ReadOptions, error) {
+	delim, err := csvio.ParseDelimiter(f.delimiter)
+	if err != nil {
+		return csvio.ReadOptions{}, err
+	}
+	enc, err := csvio.ParseEncoding(f.encoding)
+	if err != nil {
+		return csvio.ReadOptions{}, err
+	}
+	opts := csvio.ReadOptions{
+		Encoding:   enc,
+		Delimiter:  delim,
+		LazyQuotes: f.lazyQuotes,
+		Header:     csvio.HeaderMode(strings.ToLower(f.header)),
+		Ragged:     csvio.RaggedMode(strings.ToLower(f.ragged)),
+		MaxRows:    f.maxRows,
+	}
+	if f.comment != "" {
+		runes := []rune(f.comment)
+		if len(runes) != 1 {
+			return csvio.ReadOptions{}, errors.New("--comment must be a single character")
+		}
+		opts.Comment = runes[0]
+	}
+	return opts, nil
+}
+
+// readTable loads a CSV file into TableData.
+func (f *ioFlags) readTable(path string) (types.TableData, error) {
+	opts, err := f.readOptions()
+	if err != nil {
+		return types.TableData{}, err
+	}
+	return csvio.ReadFile(path, opts)
+}
+
+// readNamedTables loads every path as a NamedTable named after its file.
+func (f *ioFlags) readNamedTables(paths []string) ([]types.NamedTable, error) {
+	names, err := tableNames(paths)
+	if err != nil {
+		return nil, err
+	}
+	tables := make([]types.NamedTable, 0, len(paths))
+	for i, p := range paths {
+		tbl, err := f.readTable(p)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, types.NamedTable{Name: names[i], Table: tbl})
+	}
+	return tables, nil
+}
+
+// writeResult writes tbl to <out>/<name>.csv and reports it on stdout.
+func (f *ioFlags) writeResult(name string, tbl types.TableData) error {
+	enc, err := csvio.ParseEncoding(f.outEncoding)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(f.out, name+".csv")
+	if err := csvio.WriteFile(path, tbl, csvio.WriteOptions{Encoding: enc}); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s (%d rows)\n", path, len(tbl.Rows))
+	return nil
+}
+
+// stringList is a repeatable flag value.
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// keyNormalizers parses a comma-separated --normalize value.
+func keyNormalizers(s string) []csvops.KeyNormalizer {
+	var out []csvops.KeyNormalizer
+	for _, name := range splitList(s) {
+		out = append(out, csvops.KeyNormalizer(name))
+	}
+	return out
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	parts := strings.Split(s, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: csvops %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses flags that may appear before, between or after the positional
+// arguments (e.g. `crossref list.csv master.csv --key Email`) and returns the positionals.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// tableName derives a dataset name from a file path ("data/list.csv" -> "list").
+func tableName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// tableNames returns the tableName of every path. Outputs are named after the
+// tables, so two inputs with the same name (e.g. a/x.csv and b/x.csv) are an error.
+func tableNames(paths []string) ([]string, error) {
+	names := make([]string, len(paths))
+	seen := make(map[string]string, len(paths))
+	for i, p := range paths {
+		name := tableName(p)
+		if prev, ok := seen[name]; ok {
+			return nil, fmt.Errorf("%s and %s both have the table name %q; rename one of them", prev, p, name)
+		}
+		seen[name] = p
+		names[i] = name
+	}
+	return names, nil
+}
+
+// reportListError prints a non-fatal per-list error returned by an operation.
+func reportListError(name string, msg *string) bool {
+	if msg == nil {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "%s: %s\n", name, *msg)
+	return true
+}
+
+// outputFile is a result CSV written row by row.
+type outputFile struct {
+	*csvio.Writer
+	path string
+	file *os.File
+}
+
+// close flushes the writer and closes the file. Discarded outputs are removed.
+func (o *outputFile) close(discard bool) error {
+	err := o.Writer.Close()
+	if cerr := o.file.Close(); err == nil {
+		err = cerr
+	}
+	if discard {
+		os.Remove(o.path)
+		return nil
+	}
+	if err == nil {
+		fmt.Printf("wrote %s\n", o.path)
+	}
+	return err
+}
+
+// createOutput creates <out>/<name>.csv for row-at-a-time writing.
+func (f *ioFlags) createOutput(name string) (*outputFile, error) {
+	enc, err := csvio.ParseEncoding(f.outEncoding)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(f.out, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create output dir: %w", err)
+	}
+	path := filepath.Join(f.out, name+".csv")
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CSV: %w", err)
+	}
+	w, err := csvio.NewWriter(file, csvio.WriteOptions{Encoding: enc})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &outputFile{Writer: w, path: path, file: file}, nil
+}
+
+// runStreamed opens every path for streaming, pairs it with <out>/<name><suffix>.csv,
+// runs fn and closes all files afterwards, so inputs never have to fit in memory.
+// fn returns the names of tables whose outputs are unwanted (they failed, or a dry
+// run wrote nothing to them); those outputs are removed.
+func (f *ioFlags) runStreamed(paths []string, suffix string, fn func([]csvops.StreamTable) ([]string, error)) error {
+	opts, err := f.readOptions()
+	if err != nil {
+		return err
+	}
+	names, err := tableNames(paths)
+	if err != nil {
+		return err
+	}
+
+	var inputs []*os.File
+	var outputs []*outputFile
+	discard := map[string]bool{}
+	closeAll := func() error {
+		var first error
+		for _, in := range inputs {
+			in.Close()
+		}
+		for i, out := range outputs {
+			if err := out.close(discard[names[i]]); err != nil && first == nil {
+				first = err
+			}
+		}
+		return first
+	}
+
+	tables := make([]csvops.StreamTable, 0, len(paths))
+	for i, p := range paths {
+		in, err := os.Open(p)
+		if err != nil {
+			closeAll()
+			return fmt.Errorf("failed to open CSV: %w", err)
+		}
+		inputs = append(inputs, in)
+
+		fileOpts := opts
+		if fileOpts.Delimiter == 0 {
+			fileOpts.Delimiter = csvio.DelimiterForPath(p)
+		}
+		rd, err := csvio.NewReader(in, fileOpts)
+		if err != nil {
+			closeAll()
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		out, err := f.createOutput(names[i] + suffix)
+		if err != nil {
+			closeAll()
+			return err
+		}
+		outputs = append(outputs, out)
+		tables = append(tables, csvops.StreamTable{Name: names[i], Input: rd.Stream(), Output: out})
+	}
+
+	unwanted, err := fn(tables)
+	if err != nil {
+		// nothing useful was written
+		for _, name := range names {
+			discard[name] = true
+		}
+	}
+	for _, name := range unwanted {
+		discard[name] = true
+	}
+	if cerr := closeAll(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/csvops"
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+)
+
+// lookupFlags are the batch lookup flags of one-to-many and many-to-one.
+type lookupFlags struct {
+	values  stringList
+	file    string
+	columns stringList
+}
+
+func (l *lookupFlags) register(fs *flag.FlagSet) {
+	fs.Var(&l.values, "lookup", "value to look up in a batch instead of --value (repeatable)")
+	fs.StringVar(&l.file, "lookup-file", "", "CSV file whose --lookup-column values are looked up in a batch")
+	fs.Var(&l.columns, "lookup-column", "column of --lookup-file holding the values (repeat once per --key; default the first column)")
+}
+
+// batch reports whether a batch lookup was requested.
+func (l *lookupFlags) batch() bool {
+	return len(l.values) > 0 || l.file != ""
+}
+
+// source reads --lookup-file (nil when it is not set).
+func (l *lookupFlags) source(iof *ioFlags) (*csvops.LookupSource, error) {
+	if l.file == "" {
+		return nil, nil
+	}
+	tbl, err := iof.readTable(l.file)
+	if err != nil {
+		return nil, err
+	}
+	src := &csvops.LookupSource{Table: tbl, Columns: l.columns}
+	if len(l.columns) == 0 {
+		src.Column = "0"
+	}
+	return src, nil
+}
+
+// writeLookupHits prints the lookup values without hits and writes the hits per
+// value to lookup_hits.csv (tables lists "name:count" per table with hits).
+func (f *ioFlags) writeLookupHits(perValue []csvops.LookupHits, noHits []string) error {
+	if len(perValue) == 0 {
+		return nil
+	}
+	fmt.Printf("lookup: %d values, %d without hits\n", len(perValue), len(noHits))
+	tbl := types.TableData{
+		HasHeader: true,
+		Header:    []string{"value", "matched", "tables"},
+		Rows:      make([][]string, 0, len(perValue)),
+	}
+	for _, h := range perValue {
+		names := make([]string, 0, len(h.Tables))
+		for name := range h.Tables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for i, name := range names {
+			names[i] = name + ":" + strconv.Itoa(h.Tables[name])
+		}
+		tbl.Rows = append(tbl.Rows, []string{h.Value, strconv.Itoa(h.Matched), strings.Join(names, " ")})
+	}
+	return f.writeResult("lookup_hits", tbl)
+}
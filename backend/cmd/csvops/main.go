@@ -1,57 +1,77 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
-
-	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/csvops"
-	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+	"os"
+	"strings"
 )
 
-func main() {
-	// sample dataset with Country variations
-	data := types.TableData{
-		HasHeader: true,
-		Header:    []string{"ID", "Name", "Country"},
-		Rows: [][]string{
-			{"1", "Alice", "USA"},
-			{"2", "Bob", "U.S."},
-			{"3", "Carol", "United States of America"},
-			{"4", "Dave", "United states of america"},
-			{"5", "Eve", "Canada"},
-			{"6", "Faythe", "U.S.A"},
-			{"7", "Grace", "u.s."},
-		},
-	}
+// csvops is the command line front end for the operations in internal/csvops.
+// Every subcommand reads CSV files from disk, maps its flags onto the matching
+// request struct and writes the result tables as CSV files to an output directory.
 
-	// build rules: map multiple variants to "USA"
-	rule := csvops.ReplaceRule{
-		Targets:     []string{"USA", "U.S.", "U.S.A", "United States of America", "United states of america", "u.s."},
-		Replacement: "USA",
-		// use nil CaseInsensitive to inherit global; set WholeCell true to only match whole cell (we want that)
-		WholeCell: func(b bool) *bool { return &b }(true),
-	}
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
 
-	req := csvops.FindReplaceRequest{
-		Operation: "find_replace",
-		Options: csvops.FindReplaceOptions{
-			TrimSpaces:      true,
-			CaseInsensitive: true, // default for rules
-			Columns:         []string{"Country"},
-		},
-		Dataset: data,
-		Rules:   []csvops.ReplaceRule{rule},
+var commands = []command{
+	{"crossref", "keep list rows whose key exists in the master (LIST... MASTER)", runCrossRef},
+	{"join", "append master columns to matching list rows (LIST... MASTER)", runJoin},
+	{"difference", "list rows whose key is not in the master (LIST... MASTER)", setOpCommand("difference")},
+	{"intersection", "list rows whose key is also in the master (LIST... MASTER)", setOpCommand("intersection")},
+	{"union", "master rows plus list rows with new keys (LIST... MASTER)", setOpCommand("union")},
+	{"symdiff", "rows whose key is on only one side (LIST... MASTER)", setOpCommand("symdiff")},
+	{"clean", "trim, collapse whitespace and standardise case (FILE...)", runClean},
+	{"sort", "sort rows by a key column (FILE...)", runSort},
+	{"extract", "keep rows matching a filter (FILE)", runExtract},
+	{"replace", "find and replace cell values (FILE)", runReplace},
+	{"one-to-many", "find every row for one key value across master and lists (MASTER LIST...)", runOneToMany},
+	{"many-to-one", "rows and distinct many-column values for one value, or for every value (FILE)", runManyToOne},
+	{"traverse", "follow key relationships between tables from a start value (FILE...)", runTraverse},
+	{"run", "run the operation described by a JSON request file (REQUEST.json)", runRequest},
+	{"csv2json", "convert a CSV file to TableData JSON", runCSV2JSON},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: csvops <command> [flags] [files]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", c.name, c.summary)
 	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "run 'csvops <command> -h' for the flags of a command")
+}
 
-	resp, err := csvops.FindAndReplace(req)
-	if err != nil {
-		log.Fatalf("FindAndReplace failed: %v", err)
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
 	}
 
-	out, err := json.MarshalIndent(resp, "", "  ")
-	if err != nil {
-		log.Fatalf("json marshal failed: %v", err)
+	name := strings.TrimSpace(os.Args[1])
+	if name == "-h" || name == "--help" || name == "help" {
+		usage()
+		return
+	}
+	for _, c := range commands {
+		if c.name == name {
+			err := c.run(os.Args[2:])
+			if errors.Is(err, flag.ErrHelp) {
+				return
+			}
+			if err != nil {
+				log.Fatalf("%s: %v", c.name, err)
+			}
+			return
+		}
 	}
-	fmt.Println(string(out))
+	usage()
+	log.Fatalf("unknown command %q", name)
 }
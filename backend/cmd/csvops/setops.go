@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/csvops"
+)
+
+// setOps maps the set commands onto their operations and result file suffixes.
+var setOps = map[string]struct {
+	op     csvops.SetOp
+	fn     func(csvops.SetOpRequest) (csvops.SetOpResponse, error)
+	suffix string
+}{
+	"difference":   {csvops.SetOpDifference, csvops.SetDifference, "_difference"},
+	"intersection": {csvops.SetOpIntersection, csvops.SetIntersection, "_intersection"},
+	"union":        {csvops.SetOpUnion, csvops.SetUnion, "_union"},
+	"symdiff":      {csvops.SetOpSymmetricDifference, csvops.SymmetricDifference, "_symdiff"},
+}
+
+// setOpCommand returns the command that runs the named set operation for
+// every list against the master (LIST... MASTER, like crossref).
+func setOpCommand(name string) func(args []string) error {
+	return func(args []string) error {
+		so := setOps[name]
+		fs := newFlagSet(name, "LIST... MASTER")
+		var iof ioFlags
+		iof.register(fs)
+		var keys, listKeys stringList
+		fs.Var(&keys, "key", "master key column (header name or numeric index; repeat for a composite key)")
+		fs.Var(&listKeys, "list-key", "list key column when it differs from --key (repeat in --key order)")
+		match := fs.String("match", string(csvops.MatchExact), "match method: exact | case_insensitive")
+		trim := fs.Bool("trim", false, "trim and collapse whitespace before comparing")
+		normalizers := fs.String("normalize", "", "comma-separated key normalizers applied in order: "+strings.Join(csvops.KeyNormalizerNames(), " | "))
+		wholeRow := fs.Bool("whole-row", false, "compare entire rows instead of a key column")
+		side := fs.String("side", string(csvops.SideList), "difference/intersection: return rows of the list | master")
+		files, err := parseArgs(fs, args)
+		if err != nil {
+			return err
+		}
+		if len(files) < 2 {
+			fs.Usage()
+			return errors.New("need at least one list file followed by the master file")
+		}
+
+		master, err := iof.readTable(files[len(files)-1])
+		if err != nil {
+			return err
+		}
+		lists, err := iof.readNamedTables(files[:len(files)-1])
+		if err != nil {
+			return err
+		}
+		req := csvops.SetOpRequest{
+			Operation: string(so.op),
+			Options: csvops.SetOpOptions{
+				CrossRefMultiOptions: csvops.CrossRefMultiOptions{
+					MatchMethod:     csvops.MatchMethod(*match),
+					MasterKeys:      keys,
+					DefaultListKeys: listKeys,
+					TrimSpaces:      *trim,
+					Normalizers:     keyNormalizers(*normalizers),
+				},
+				WholeRow: *wholeRow,
+				Side:     csvops.SetSide(*side),
+			},
+		}
+		req.Datasets.Master = master
+		req.Datasets.Lists = lists
+		resp, err := so.fn(req)
+		if err != nil {
+			return err
+		}
+		for _, pl := range resp.PerList {
+			if reportListError(pl.Name, pl.Error) {
+				continue
+			}
+			fmt.Printf("%s: processed %d, matched %d, missing %d, master only %d\n", pl.Name, pl.Processed, pl.Matched, pl.Missing, pl.MasterOnly)
+			if err := iof.writeResult(pl.Name+so.suffix, pl.Result); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/csvops"
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+)
+
+// parseRelation parses a --rel value: TABLE:FROM[@TYPE]=TO[@TYPE].
+func parseRelation(s string) (csvops.Relation, error) {
+	table, cols, ok := strings.Cut(s, ":")
+	from, to, ok2 := strings.Cut(cols, "=")
+	if !ok || !ok2 || table == "" || from == "" || to == "" {
+		return csvops.Relation{}, fmt.Errorf("bad --rel %q: want TABLE:FROM[@TYPE]=TO[@TYPE]", s)
+	}
+	r := csvops.Relation{Table: table}
+	r.From, r.FromType, _ = strings.Cut(from, "@")
+	r.To, r.ToType, _ = strings.Cut(to, "@")
+	return r, nil
+}
+
+func runTraverse(args []string) error {
+	fs := newFlagSet("traverse", "FILE...")
+	var iof ioFlags
+	iof.register(fs)
+	var rels stringList
+	fs.Var(&rels, "rel", "relation TABLE:FROM[@TYPE]=TO[@TYPE]; TABLE is a file name without extension, TYPE defaults to the column name (repeatable)")
+	startFlag := fs.String("start", "", "start node TYPE=VALUE, e.g. device=pc1")
+	depth := fs.Int("depth", csvops.DefaultTraverseDepth, "hops to follow from the start node")
+	maxNodes := fs.Int("max-nodes", csvops.DefaultTraverseMaxNodes, "stop adding nodes beyond this many")
+	directed := fs.Bool("directed", false, "only follow relations from FROM to TO")
+	caseInsensitive := fs.Bool("case-insensitive", false, "match values case-insensitively")
+	trim := fs.Bool("trim", false, "trim and collapse whitespace in values before matching")
+	normalizers := fs.String("normalize", "", "comma-separated key normalizers applied in order: "+strings.Join(csvops.KeyNormalizerNames(), " | "))
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	startType, startValue, ok := strings.Cut(*startFlag, "=")
+	if len(files) == 0 || len(rels) == 0 || !ok {
+		fs.Usage()
+		return errors.New("need input files, at least one --rel and --start TYPE=VALUE")
+	}
+
+	lists, err := iof.readNamedTables(files)
+	if err != nil {
+		return err
+	}
+	relations := make([]csvops.Relation, 0, len(rels))
+	for _, s := range rels {
+		r, err := parseRelation(s)
+		if err != nil {
+			return err
+		}
+		relations = append(relations, r)
+	}
+	match := csvops.MatchExact
+	if *caseInsensitive {
+		match = csvops.MatchCaseInsensitive
+	}
+	req := csvops.TraverseRequest{
+		Operation: "traverse",
+		Options: csvops.TraverseOptions{
+			MatchMethod: match,
+			TrimSpaces:  *trim,
+			Normalizers: keyNormalizers(*normalizers),
+			MaxDepth:    *depth,
+			MaxNodes:    *maxNodes,
+			Directed:    *directed,
+		},
+		Start:     csvops.TraverseStart{Type: startType, Value: startValue},
+		Relations: relations,
+		Datasets:  types.MultiDatasets{Lists: lists},
+	}
+	resp, err := csvops.Traverse(req)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("traverse: %d nodes, %d edges, depth %d\n", resp.Summary["nodes"], resp.Summary["edges"], resp.Summary["depth"])
+	if resp.Truncated {
+		fmt.Fprintf(os.Stderr, "graph truncated at %d nodes\n", *maxNodes)
+	}
+	if err := iof.writeResult("nodes", resp.Nodes); err != nil {
+		return err
+	}
+	if err := iof.writeResult("edges", resp.Edges); err != nil {
+		return err
+	}
+	graph, err := json.MarshalIndent(resp.Graph, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := iof.writeFile("graph.json", append(graph, '\n')); err != nil {
+		return err
+	}
+	return iof.writeFile("graph.dot", []byte(resp.DOT))
+}
+
+// writeFile writes a non-CSV result to <out>/<name> and reports it on stdout.
+func (f *ioFlags) writeFile(name string, data []byte) error {
+	if err := os.MkdirAll(f.out, 0o755); err != nil {
+		return fmt.Errorf("failed to create output dir: %w", err)
+	}
+	path := filepath.Join(f.out, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s\n", path)
+	return nil
+}
@@ -0,0 +1,248 @@
+package csvio
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Encoding names a character encoding for input or output files.
+type Encoding string
+
+const (
+	EncodingAuto        Encoding = "auto"         // input only: BOM sniffing, then UTF-16/UTF-8/Windows-1252 heuristics (see DetectEncoding)
+	EncodingUTF8        Encoding = "utf-8"        // a leading BOM is stripped on input
+	EncodingUTF8BOM     Encoding = "utf-8-bom"    // output writes a BOM (what Excel expects)
+	EncodingUTF16LE     Encoding = "utf-16le"     // output writes a BOM
+	EncodingUTF16BE     Encoding = "utf-16be"     // output writes a BOM
+	EncodingWindows1252 Encoding = "windows-1252" // Excel "ANSI" on western Windows
+	EncodingLatin1      Encoding = "iso-8859-1"
+)
+
+// encodingAliases maps common spellings onto the canonical names.
+var encodingAliases = map[string]Encoding{
+	"":             EncodingAuto,
+	"auto":         EncodingAuto,
+	"utf-8":        EncodingUTF8,
+	"utf8":         EncodingUTF8,
+	"utf-8-bom":    EncodingUTF8BOM,
+	"utf-8-sig":    EncodingUTF8BOM,
+	"utf8bom":      EncodingUTF8BOM,
+	"utf-16le":     EncodingUTF16LE,
+	"utf16le":      EncodingUTF16LE,
+	"utf-16":       EncodingUTF16LE,
+	"utf-16be":     EncodingUTF16BE,
+	"utf16be":      EncodingUTF16BE,
+	"windows-1252": EncodingWindows1252,
+	"cp1252":       EncodingWindows1252,
+	"ansi":         EncodingWindows1252,
+	"iso-8859-1":   EncodingLatin1,
+	"latin1":       EncodingLatin1,
+}
+
+// ParseEncoding resolves an encoding name (case-insensitive, common aliases accepted).
+func ParseEncoding(s string) (Encoding, error) {
+	if enc, ok := encodingAliases[strings.ToLower(strings.TrimSpace(s))]; ok {
+		return enc, nil
+	}
+	return "", fmt.Errorf("unsupported encoding '%s'", s)
+}
+
+// textEncoding returns the x/text implementation for enc.
+func textEncoding(enc Encoding) (encoding.Encoding, error) {
+	switch enc {
+	case EncodingUTF8:
+		return unicode.UTF8, nil
+	case EncodingUTF8BOM:
+		return unicode.UTF8BOM, nil
+	case EncodingUTF16LE:
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), nil
+	case EncodingUTF16BE:
+		return unicode.UTF16(unicode.BigEndian, unicode.UseBOM), nil
+	case EncodingWindows1252:
+		return charmap.Windows1252, nil
+	case EncodingLatin1:
+		return charmap.ISO8859_1, nil
+	}
+	return nil, fmt.Errorf("unsupported encoding '%s'", enc)
+}
+
+// sniffSize is how many leading bytes DetectEncoding looks at.
+const sniffSize = 64 * 1024
+
+// DetectEncoding guesses the encoding of a file from its first bytes: a BOM wins,
+// then NUL-byte patterns indicate BOM-less UTF-16, then valid UTF-8 is taken as
+// UTF-8 and anything else as Windows-1252. Readers only pass it the first
+// sniffSize bytes; with EncodingAuto they keep checking a UTF-8 guess while
+// reading and switch to Windows-1252 at the first invalid byte.
+func DetectEncoding(head []byte) Encoding {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		return EncodingUTF8
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		return EncodingUTF16BE
+	}
+
+	var evenNUL, oddNUL int
+	for i, b := range head {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenNUL++
+		} else {
+			oddNUL++
+		}
+	}
+	if half := len(head) / 2; half > 0 {
+		if oddNUL*10 > half*3 && evenNUL*10 < half {
+			return EncodingUTF16LE
+		}
+		if evenNUL*10 > half*3 && oddNUL*10 < half {
+			return EncodingUTF16BE
+		}
+	}
+
+	// the sniffed window may cut a multi-byte rune in half; ignore a partial tail
+	for i := 0; i < utf8.UTFMax && len(head) > 0; i++ {
+		if utf8.Valid(head) {
+			return EncodingUTF8
+		}
+		head = head[:len(head)-1]
+	}
+	if utf8.Valid(head) {
+		return EncodingUTF8
+	}
+	return EncodingWindows1252
+}
+
+// decodeReader wraps r so it yields UTF-8 for the given input encoding and
+// returns a func reporting the encoding actually used (the detected one for
+// EncodingAuto, which can change to Windows-1252 while reading; see utf8Fallback).
+func decodeReader(r io.Reader, enc Encoding) (io.Reader, func() Encoding, error) {
+	if enc == "" {
+		enc = EncodingAuto
+	}
+	if enc == EncodingAuto {
+		br := bufio.NewReaderSize(r, sniffSize)
+		head, err := br.Peek(sniffSize)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return nil, nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		enc = DetectEncoding(head)
+		r = br
+		if enc == EncodingUTF8 && !bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}) {
+			// only the head was checked; keep checking while the rest streams by
+			fb := &utf8Fallback{}
+			used := func() Encoding {
+				if fb.fallback != nil {
+					return EncodingWindows1252
+				}
+				return EncodingUTF8
+			}
+			return transform.NewReader(r, fb), used, nil
+		}
+	}
+	if enc == EncodingUTF8BOM {
+		enc = EncodingUTF8
+	}
+	te, err := textEncoding(enc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if enc == EncodingUTF8 {
+		// UTF8BOM's decoder strips a leading BOM and passes everything else through
+		te = unicode.UTF8BOM
+	}
+	return transform.NewReader(r, te.NewDecoder()), func() Encoding { return enc }, nil
+}
+
+// utf8Fallback passes valid UTF-8 through and decodes everything from the first
+// invalid byte on as Windows-1252. It backs EncodingAuto for files whose sniffed
+// head was valid UTF-8, so a Windows-1252 file whose first accented character
+// comes after the head is still decoded instead of being mangled.
+type utf8Fallback struct {
+	fallback transform.Transformer // set once an invalid byte was seen
+}
+
+func (t *utf8Fallback) Reset() { t.fallback = nil }
+
+func (t *utf8Fallback) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	if t.fallback != nil {
+		return t.fallback.Transform(dst, src, atEOF)
+	}
+	n := 0
+	for n < len(src) {
+		if src[n] < utf8.RuneSelf {
+			n++
+			continue
+		}
+		if !atEOF && !utf8.FullRune(src[n:]) {
+			// a rune split across reads: wait for the rest
+			nDst, nSrc = copyValid(dst, src[:n])
+			if nSrc < n {
+				return nDst, nSrc, transform.ErrShortDst
+			}
+			return nDst, nSrc, transform.ErrShortSrc
+		}
+		r, size := utf8.DecodeRune(src[n:])
+		if r == utf8.RuneError && size <= 1 {
+			nDst, nSrc = copyValid(dst, src[:n])
+			if nSrc < n {
+				return nDst, nSrc, transform.ErrShortDst
+			}
+			t.fallback = charmap.Windows1252.NewDecoder()
+			d, s, err := t.fallback.Transform(dst[nDst:], src[n:], atEOF)
+			return nDst + d, nSrc + s, err
+		}
+		n += size
+	}
+	nDst, nSrc = copyValid(dst, src)
+	if nSrc < len(src) {
+		return nDst, nSrc, transform.ErrShortDst
+	}
+	return nDst, nSrc, nil
+}
+
+// copyValid copies as much of the valid UTF-8 in src to dst as fits without
+// splitting a rune and returns the bytes copied.
+func copyValid(dst, src []byte) (int, int) {
+	if len(src) <= len(dst) {
+		n := copy(dst, src)
+		return n, n
+	}
+	n := len(dst)
+	for n > 0 && !utf8.RuneStart(src[n]) {
+		n--
+	}
+	copy(dst, src[:n])
+	return n, n
+}
+
+// encodeWriter wraps w so UTF-8 written to it is stored in the given output
+// encoding. The returned closer flushes the encoder and must be called once.
+func encodeWriter(w io.Writer, enc Encoding) (io.Writer, func() error, error) {
+	if enc == "" || enc == EncodingUTF8 {
+		return w, func() error { return nil }, nil
+	}
+	if enc == EncodingAuto {
+		return nil, nil, fmt.Errorf("encoding '%s' is only valid for input", enc)
+	}
+	te, err := textEncoding(enc)
+	if err != nil {
+		return nil, nil, err
+	}
+	// characters the target cannot represent are replaced instead of aborting the write
+	tw := transform.NewWriter(w, encoding.ReplaceUnsupported(te.NewEncoder()))
+	return tw, tw.Close, nil
+}
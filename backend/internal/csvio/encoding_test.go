@@ -0,0 +1,55 @@
+package csvio
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReaderFallsBackToWindows1252AfterSniffedHead(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefix   int    // ASCII rows before the last one
+		last     []byte // raw bytes of the last row
+		want     string
+		wantUsed Encoding
+	}{
+		{"ascii", 10, []byte("plain"), "plain", EncodingUTF8},
+		{"utf-8 past head", 5000, []byte("caf\xc3\xa9"), "café", EncodingUTF8},
+		{"windows-1252 in head", 10, []byte("caf\xe9"), "café", EncodingWindows1252},
+		{"windows-1252 past head", 5000, []byte("caf\xe9 \x93q\x94"), "café “q”", EncodingWindows1252},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			buf.WriteString("name\n")
+			for i := 0; i < tt.prefix; i++ {
+				buf.WriteString("row number padding the sniffed head\n")
+			}
+			buf.Write(tt.last)
+			buf.WriteString("\n")
+			if tt.prefix > 100 && buf.Len() <= sniffSize {
+				t.Fatalf("input of %d bytes does not extend past the sniffed head", buf.Len())
+			}
+
+			rd, err := NewReader(&buf, ReadOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var last []string
+			for {
+				row, err := rd.Next()
+				if err != nil {
+					break
+				}
+				last = row
+			}
+			if got := strings.Join(last, ","); got != tt.want {
+				t.Errorf("last row = %q, want %q", got, tt.want)
+			}
+			if got := rd.Encoding(); got != tt.wantUsed {
+				t.Errorf("Encoding() = %s, want %s", got, tt.wantUsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+// Package csvio loads delimited files into types.TableData and writes them back,
+// so every operation and command reads files the same way.
+package csvio
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+)
+
+// HeaderMode controls how the first row of a file is treated.
+type HeaderMode string
+
+const (
+	HeaderPresent HeaderMode = "true"  // first row is the header (default)
+	HeaderAbsent  HeaderMode = "false" // every row is data
+	HeaderAuto    HeaderMode = "auto"  // guess from the first row
+)
+
+// RaggedMode controls what happens to rows whose field count differs from the table width.
+// The width is the header length, or the first row length for headerless files.
+type RaggedMode string
+
+const (
+	RaggedKeep     RaggedMode = "keep"     // keep rows as read (default)
+	RaggedPad      RaggedMode = "pad"      // pad short rows with empty cells, keep long rows
+	RaggedTruncate RaggedMode = "truncate" // pad short rows and cut long rows to the width
+	RaggedError    RaggedMode = "error"    // fail on the first row with a different width
+)
+
+// ReadOptions configures how a delimited file is parsed.
+type ReadOptions struct {
+	Delimiter  rune       // field separator; 0 => ','
+	Comment    rune       // lines starting with this rune are skipped; 0 => none
+	LazyQuotes bool       // allow quotes in unquoted fields and unescaped quotes in quoted fields
+	Header     HeaderMode // "" => HeaderPresent
+	Ragged     RaggedMode // "" => RaggedKeep
+	MaxRows    int        // maximum data rows to read (header excluded); 0 => unlimited
+	Encoding   Encoding   // input character encoding; "" => EncodingAuto
+}
+
+// Reader reads a delimited file one row at a time. The header (if any) is
+// resolved when the Reader is created so callers can inspect it before the rows.
+type Reader struct {
+	csv       *csv.Reader
+	opts      ReadOptions
+	encoding  func() Encoding
+	hasHeader bool
+	header    []string
+	width     int
+	pending   []string // first row when it turned out to be data
+	rows      int
+}
+
+// NewReader wraps r, decodes it to UTF-8 and resolves the header according to opts.
+func NewReader(r io.Reader, opts ReadOptions) (*Reader, error) {
+	decoded, enc, err := decodeReader(r, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	cr := csv.NewReader(decoded)
+	if opts.Delimiter != 0 {
+		cr.Comma = opts.Delimiter
+	}
+	cr.Comment = opts.Comment
+	cr.LazyQuotes = opts.LazyQuotes
+	cr.FieldsPerRecord = -1 // width checks are done by applyRagged
+
+	if opts.Header == "" {
+		opts.Header = HeaderPresent
+	}
+	if opts.Ragged == "" {
+		opts.Ragged = RaggedKeep
+	}
+	switch opts.Header {
+	case HeaderPresent, HeaderAbsent, HeaderAuto:
+	default:
+		return nil, fmt.Errorf("invalid header mode '%s'", opts.Header)
+	}
+	switch opts.Ragged {
+	case RaggedKeep, RaggedPad, RaggedTruncate, RaggedError:
+	default:
+		return nil, fmt.Errorf("invalid ragged mode '%s'", opts.Ragged)
+	}
+
+	rd := &Reader{csv: cr, opts: opts, encoding: enc, width: -1}
+
+	first, err := rd.readRecord()
+	if err == io.EOF {
+		rd.hasHeader = opts.Header == HeaderPresent
+		return rd, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch opts.Header {
+	case HeaderPresent:
+		rd.hasHeader = true
+	case HeaderAuto:
+		rd.hasHeader = looksLikeHeader(first)
+	}
+
+	if rd.hasHeader {
+		rd.header = first
+	} else {
+		rd.pending = first
+	}
+	rd.width = len(first)
+	return rd, nil
+}
+
+// HasHeader reports whether the first row was taken as the header.
+func (rd *Reader) HasHeader() bool { return rd.hasHeader }
+
+// Encoding returns the input encoding in use (the detected one when opts.Encoding
+// was auto; a UTF-8 guess turns into Windows-1252 once an invalid byte has been read).
+func (rd *Reader) Encoding() Encoding { return rd.encoding() }
+
+// Header returns the header row, or nil for headerless files.
+func (rd *Reader) Header() []string { return rd.header }
+
+// Next returns the next data row, or io.EOF after the last row (or MaxRows).
+func (rd *Reader) Next() ([]string, error) {
+	if rd.opts.MaxRows > 0 && rd.rows >= rd.opts.MaxRows {
+		return nil, io.EOF
+	}
+	row := rd.pending
+	if row != nil {
+		rd.pending = nil
+	} else {
+		var err error
+		row, err = rd.readRecord()
+		if err != nil {
+			return nil, err
+		}
+	}
+	rd.rows++
+	return rd.applyRagged(row)
+}
+
+func (rd *Reader) readRecord() ([]string, error) {
+	rec, err := rd.csv.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV: %w", err)
+	}
+	return rec, nil
+}
+
+// applyRagged enforces opts.Ragged against the table width.
+func (rd *Reader) applyRagged(row []string) ([]string, error) {
+	if rd.width < 0 || len(row) == rd.width {
+		return row, nil
+	}
+	switch rd.opts.Ragged {
+	case RaggedError:
+		line, _ := rd.csv.FieldPos(0)
+		return nil, fmt.Errorf("row %d (line %d) has %d fields, expected %d", rd.rows, line, len(row), rd.width)
+	case RaggedPad, RaggedTruncate:
+		if len(row) < rd.width {
+			row = append(row, make([]string, rd.width-len(row))...)
+		} else if rd.opts.Ragged == RaggedTruncate {
+			row = row[:rd.width]
+		}
+	}
+	return row, nil
+}
+
+// ReadAll drains the reader into a TableData.
+func (rd *Reader) ReadAll() (types.TableData, error) {
+	tbl := types.TableData{
+		HasHeader: rd.hasHeader,
+		Header:    rd.header,
+		Rows:      [][]string{},
+	}
+	for {
+		row, err := rd.Next()
+		if err == io.EOF {
+			return tbl, nil
+		}
+		if err != nil {
+			return types.TableData{}, err
+		}
+		tbl.Rows = append(tbl.Rows, row)
+	}
+}
+
+// Read parses r into a TableData.
+func Read(r io.Reader, opts ReadOptions) (types.TableData, error) {
+	rd, err := NewReader(r, opts)
+	if err != nil {
+		return types.TableData{}, err
+	}
+	return rd.ReadAll()
+}
+
+// ReadFile opens path and parses it into a TableData. When opts.Delimiter is 0
+// the delimiter is chosen from the file extension (see DelimiterForPath).
+func ReadFile(path string, opts ReadOptions) (types.TableData, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return types.TableData{}, fmt.Errorf("failed to open CSV: %w", err)
+	}
+	defer f.Close()
+
+	if opts.Delimiter == 0 {
+		opts.Delimiter = DelimiterForPath(path)
+	}
+	tbl, err := Read(f, opts)
+	if err != nil {
+		return types.TableData{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return tbl, nil
+}
+
+// DelimiterForPath guesses the delimiter from the file extension:
+// .tsv/.tab => tab, .psv => '|', anything else => ','.
+func DelimiterForPath(path string) rune {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tsv", ".tab":
+		return '\t'
+	case ".psv":
+		return '|'
+	}
+	return ','
+}
+
+// ParseDelimiter accepts a single character or one of the names
+// comma, tab, pipe, semicolon (also "\t").
+func ParseDelimiter(s string) (rune, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return 0, nil
+	case "comma":
+		return ',', nil
+	case "tab", `\t`:
+		return '\t', nil
+	case "pipe":
+		return '|', nil
+	case "semicolon":
+		return ';', nil
+	}
+	runes := []rune(s)
+	if len(runes) != 1 {
+		return 0, errors.New("delimiter must be a single character or comma|tab|pipe|semicolon")
+	}
+	if runes[0] == '"' || runes[0] == '\r' || runes[0] == '\n' {
+		return 0, fmt.Errorf("invalid delimiter %q", s)
+	}
+	return runes[0], nil
+}
+
+var dateLike = regexp.MustCompile(`^\d{1,4}[-/.]\d{1,2}[-/.]\d{1,4}`)
+
+// looksLikeData reports whether a cell is a typical data value (number or date)
+// rather than a column name.
+func looksLikeData(cell string) bool {
+	c := strings.TrimSpace(cell)
+	if c == "" {
+		return true
+	}
+	if _, err := strconv.ParseFloat(strings.ReplaceAll(c, ",", ""), 64); err == nil {
+		return true
+	}
+	return dateLike.MatchString(c)
+}
+
+// looksLikeHeader guesses whether first is a header row: every cell must be a
+// non-empty, unique label that does not look like a number or a date.
+func looksLikeHeader(first []string) bool {
+	seen := make(map[string]struct{}, len(first))
+	for _, c := range first {
+		if looksLikeData(c) {
+			return false
+		}
+		key := strings.ToLower(strings.TrimSpace(c))
+		if _, dup := seen[key]; dup {
+			return false
+		}
+		seen[key] = struct{}{}
+	}
+	return len(first) > 0
+}
+
+// Stream exposes the reader as a types.RowStream.
+func (rd *Reader) Stream() types.RowStream {
+	return types.RowStream{HasHeader: rd.hasHeader, Header: rd.header, Rows: rd}
+}
@@ -0,0 +1,168 @@
+package csvio
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+)
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    ReadOptions
+		want    types.TableData
+		wantErr string
+	}{
+		{
+			name:  "header present",
+			input: "name,age\nann,30\nbob,41\n",
+			want:  types.TableData{HasHeader: true, Header: []string{"name", "age"}, Rows: [][]string{{"ann", "30"}, {"bob", "41"}}},
+		},
+		{
+			name:  "header absent",
+			input: "name,age\nann,30\n",
+			opts:  ReadOptions{Header: HeaderAbsent},
+			want:  types.TableData{Rows: [][]string{{"name", "age"}, {"ann", "30"}}},
+		},
+		{
+			name:  "custom delimiter and comment",
+			input: "# exported\nname;city\nann;\"Paris; FR\"\n",
+			opts:  ReadOptions{Delimiter: ';', Comment: '#'},
+			want:  types.TableData{HasHeader: true, Header: []string{"name", "city"}, Rows: [][]string{{"ann", "Paris; FR"}}},
+		},
+		{
+			name:  "auto detects a header",
+			input: "host,ip\npc1,10.0.0.1\n",
+			opts:  ReadOptions{Header: HeaderAuto},
+			want:  types.TableData{HasHeader: true, Header: []string{"host", "ip"}, Rows: [][]string{{"pc1", "10.0.0.1"}}},
+		},
+		{
+			name:  "auto keeps numeric first row as data",
+			input: "1,2024-01-05,ann\n2,2024-02-01,bob\n",
+			opts:  ReadOptions{Header: HeaderAuto},
+			want:  types.TableData{Rows: [][]string{{"1", "2024-01-05", "ann"}, {"2", "2024-02-01", "bob"}}},
+		},
+		{
+			name:  "auto keeps repeated labels as data",
+			input: "yes,yes\nno,yes\n",
+			opts:  ReadOptions{Header: HeaderAuto},
+			want:  types.TableData{Rows: [][]string{{"yes", "yes"}, {"no", "yes"}}},
+		},
+		{
+			name:  "ragged keep",
+			input: "a,b\n1\n1,2,3\n",
+			want:  types.TableData{HasHeader: true, Header: []string{"a", "b"}, Rows: [][]string{{"1"}, {"1", "2", "3"}}},
+		},
+		{
+			name:  "ragged pad",
+			input: "a,b\n1\n1,2,3\n",
+			opts:  ReadOptions{Ragged: RaggedPad},
+			want:  types.TableData{HasHeader: true, Header: []string{"a", "b"}, Rows: [][]string{{"1", ""}, {"1", "2", "3"}}},
+		},
+		{
+			name:  "ragged truncate",
+			input: "a,b\n1\n1,2,3\n",
+			opts:  ReadOptions{Ragged: RaggedTruncate},
+			want:  types.TableData{HasHeader: true, Header: []string{"a", "b"}, Rows: [][]string{{"1", ""}, {"1", "2"}}},
+		},
+		{
+			name:  "ragged pad sizes headerless files from the first row",
+			input: "1,2\n3\n",
+			opts:  ReadOptions{Header: HeaderAbsent, Ragged: RaggedPad},
+			want:  types.TableData{Rows: [][]string{{"1", "2"}, {"3", ""}}},
+		},
+		{
+			name:    "ragged error",
+			input:   "a,b\n1,2\n1,2,3\n",
+			opts:    ReadOptions{Ragged: RaggedError},
+			wantErr: "row 2 (line 3) has 3 fields, expected 2",
+		},
+		{
+			name:  "max rows",
+			input: "a\n1\n2\n3\n",
+			opts:  ReadOptions{MaxRows: 2},
+			want:  types.TableData{HasHeader: true, Header: []string{"a"}, Rows: [][]string{{"1"}, {"2"}}},
+		},
+		{
+			name:  "empty input",
+			input: "",
+			want:  types.TableData{HasHeader: true, Rows: [][]string{}},
+		},
+		{
+			name:    "invalid ragged mode",
+			input:   "a\n",
+			opts:    ReadOptions{Ragged: "squash"},
+			wantErr: "invalid ragged mode",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Read(strings.NewReader(tt.input), tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %#v\nwant %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReaderStream(t *testing.T) {
+	rd, err := NewReader(strings.NewReader("1,2\n3,4\n"), ReadOptions{Header: HeaderAuto})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := rd.Stream()
+	if st.HasHeader || st.Header != nil {
+		t.Fatalf("stream header = %v, %v, want none", st.HasHeader, st.Header)
+	}
+	var rows [][]string
+	for {
+		row, err := st.Rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+	// the first row was read to detect the header and must still be returned
+	if want := [][]string{{"1", "2"}, {"3", "4"}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+}
+
+func TestParseDelimiter(t *testing.T) {
+	tests := []struct {
+		in   string
+		want rune
+		ok   bool
+	}{
+		{"", 0, true},
+		{"tab", '\t', true},
+		{`\t`, '\t', true},
+		{"Pipe", '|', true},
+		{"semicolon", ';', true},
+		{";", ';', true},
+		{`"`, 0, false},
+		{"ab", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseDelimiter(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseDelimiter(%q) = %q, %v, want %q (ok %v)", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package csvio
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+)
+
+// WriteOptions configures how a table is written.
+type WriteOptions struct {
+	Delimiter rune     // field separator; 0 => ','
+	UseCRLF   bool     // terminate lines with \r\n instead of \n
+	Encoding  Encoding // output character encoding; "" => EncodingUTF8 (no BOM)
+}
+
+// Writer writes rows one at a time. Call Close when done; it flushes buffered
+// rows and the output encoder but does not close the underlying io.Writer.
+type Writer struct {
+	csv   *csv.Writer
+	close func() error
+}
+
+// NewWriter wraps w according to opts.
+func NewWriter(w io.Writer, opts WriteOptions) (*Writer, error) {
+	ew, closeEnc, err := encodeWriter(w, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	cw := csv.NewWriter(ew)
+	if opts.Delimiter != 0 {
+		cw.Comma = opts.Delimiter
+	}
+	cw.UseCRLF = opts.UseCRLF
+	return &Writer{csv: cw, close: closeEnc}, nil
+}
+
+// Write writes a single row.
+func (w *Writer) Write(row []string) error {
+	return w.csv.Write(row)
+}
+
+// Flush writes any buffered rows and returns the first write error, if any.
+func (w *Writer) Flush() error {
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+// Close flushes buffered rows and finishes the output encoding.
+func (w *Writer) Close() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return w.close()
+}
+
+// Write writes tbl to w, header first when tbl.HasHeader is set.
+func Write(w io.Writer, tbl types.TableData, opts WriteOptions) error {
+	cw, err := NewWriter(w, opts)
+	if err != nil {
+		return err
+	}
+	if tbl.HasHeader {
+		if err := cw.Write(tbl.Header); err != nil {
+			return fmt.Errorf("failed to write header: %w", err)
+		}
+	}
+	for _, row := range tbl.Rows {
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("failed to write row: %w", err)
+		}
+	}
+	return cw.Close()
+}
+
+// WriteFile writes tbl to path, creating parent directories as needed. When
+// opts.Delimiter is 0 the delimiter is chosen from the file extension.
+func WriteFile(path string, tbl types.TableData, opts WriteOptions) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create output dir: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create CSV: %w", err)
+	}
+	if opts.Delimiter == 0 {
+		opts.Delimiter = DelimiterForPath(path)
+	}
+	if err := Write(f, tbl, opts); err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return f.Close()
+}
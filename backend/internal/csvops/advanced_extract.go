@@ -3,6 +3,7 @@ package csvops
 import (
 	"errors"
 	"fmt"
+	"io"
 	"regexp"
 	"strconv"
 	"strings"
@@ -478,85 +479,143 @@ func evalGroup(g ConditionGroup, row []string, headerMap map[string]int, globalO
 	return false, nil
 }
 
-// AdvancedExtract executes the provided filter on the dataset and returns matching rows.
-func AdvancedExtract(req AdvancedExtractRequest) (AdvancedExtractResponse, error) {
-	var res AdvancedExtractResponse
-	res.Operation = req.Operation
-	start := time.Now()
-
-	// Validate dataset and filter
-	if req.Dataset.Rows == nil {
-		msg := "dataset required"
-		res.Error = &msg
-		return res, errors.New(msg)
-	}
-	// Build header -> index map (lowercased, trimmed keys)
+// filterHeaderMap builds the header -> index map evalGroup expects (lowercased,
+// trimmed keys) and checks that every column the filter references exists.
+func filterHeaderMap(filter ConditionGroup, header []string) (map[string]int, error) {
 	headerMap := map[string]int{}
-	for i, h := range req.Dataset.Header {
+	for i, h := range header {
 		headerMap[strings.ToLower(strings.TrimSpace(h))] = i
 	}
 
 	// Gather referenced columns and validate they exist
 	cols := map[string]struct{}{}
-	gatherColumnsFromGroup(req.Filter, cols)
+	gatherColumnsFromGroup(filter, cols)
 	for c := range cols {
 		if _, ok := headerMap[c]; !ok {
 			// build available headers list for helpful error
-			avail := strings.Join(req.Dataset.Header, ", ")
-			msg := fmt.Sprintf("filter column '%s' not found in dataset. available headers: [%s]", c, avail)
-			res.Error = &msg
-			return res, errors.New(msg)
+			avail := strings.Join(header, ", ")
+			return nil, fmt.Errorf("filter column '%s' not found in dataset. available headers: [%s]", c, avail)
 		}
 	}
+	return headerMap, nil
+}
 
-	processed := 0
-	matchedRows := make([][]string, 0, 64)
+// extractRows evaluates the filter on every row of src and writes the matches that
+// fall inside the pagination window to dst. It returns the processed and matched counts;
+// matched counts every match, not just the written page.
+func extractRows(req AdvancedExtractRequest, header []string, src types.RowReader, dst types.RowWriter) (int, int, error) {
+	headerMap, err := filterHeaderMap(req.Filter, header)
+	if err != nil {
+		return 0, 0, err
+	}
 
+	// pagination bounds
+	offset := req.Pagination.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	limit := req.Pagination.Limit
+
+	processed := 0
+	matched := 0
 	// Pre-normalization decisions are applied per condition inside evalCondition
-	for _, row := range req.Dataset.Rows {
+	for {
+		row, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return processed, matched, err
+		}
 		processed++
 		ok, err := evalGroup(req.Filter, row, headerMap, req.Options)
 		if err != nil {
-			msg := err.Error()
-			res.Error = &msg
-			return res, err
+			return processed, matched, err
 		}
-		if ok {
-			// copy row to avoid aliasing
-			matchedRows = append(matchedRows, append([]string(nil), row...))
+		if !ok {
+			continue
+		}
+		matched++
+		if matched <= offset || (limit > 0 && matched > offset+limit) {
+			continue
+		}
+		if err := dst.Write(row); err != nil {
+			return processed, matched, err
 		}
 	}
+	return processed, matched, nil
+}
 
-	// pagination
-	offset := req.Pagination.Offset
-	limit := req.Pagination.Limit
-	if limit <= 0 {
-		limit = len(matchedRows)
-	}
-	// adjust bounds
-	if offset < 0 {
-		offset = 0
-	}
-	if offset > len(matchedRows) {
-		offset = len(matchedRows)
+// AdvancedExtract executes the provided filter on the dataset and returns matching rows.
+func AdvancedExtract(req AdvancedExtractRequest) (AdvancedExtractResponse, error) {
+	var res AdvancedExtractResponse
+	res.Operation = req.Operation
+	start := time.Now()
+
+	// Validate dataset and filter
+	if req.Dataset.Rows == nil {
+		msg := "dataset required"
+		res.Error = &msg
+		return res, errors.New(msg)
 	}
-	end := offset + limit
-	if end > len(matchedRows) {
-		end = len(matchedRows)
+
+	out := newRowCollector()
+	processed, matched, err := extractRows(req, req.Dataset.Header, &sliceRows{rows: req.Dataset.Rows}, out)
+	if err != nil {
+		msg := err.Error()
+		res.Error = &msg
+		return res, err
 	}
-	pagedRows := matchedRows[offset:end]
 
 	res.Result = types.TableData{
 		HasHeader: req.Dataset.HasHeader,
 		Header:    append([]string(nil), req.Dataset.Header...),
-		Rows:      pagedRows,
+		Rows:      out.rows,
 	}
 	res.Summary = types.ResultSummary{
 		Processed:  processed,
-		Matched:    len(matchedRows),
-		Missing:    processed - len(matchedRows),
+		Matched:    matched,
+		Missing:    processed - matched,
 		DurationMS: time.Since(start).Milliseconds(),
 	}
 	res.Error = nil
 	return res, nil
 }
+
+// AdvancedExtractStream is AdvancedExtract from src to dst (see StreamTable).
+func AdvancedExtractStream(req AdvancedExtractRequest, src types.RowStream, dst types.RowWriter) (AdvancedExtractResponse, error) {
+	var res AdvancedExtractResponse
+	res.Operation = req.Operation
+	start := time.Now()
+
+	if src.Rows == nil {
+		msg := "dataset required"
+		res.Error = &msg
+		return res, errors.New(msg)
+	}
+	fail := func(err error) (AdvancedExtractResponse, error) {
+		msg := err.Error()
+		res.Error = &msg
+		return res, err
+	}
+
+	if err := writeHeader(src, dst); err != nil {
+		return fail(err)
+	}
+	processed, matched, err := extractRows(req, src.Header, src.Rows, dst)
+	if err != nil {
+		return fail(err)
+	}
+
+	res.Result = types.TableData{
+		HasHeader: src.HasHeader,
+		Header:    append([]string(nil), src.Header...),
+	}
+	res.Summary = types.ResultSummary{
+		Processed:  processed,
+		Matched:    matched,
+		Missing:    processed - matched,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	return res, nil
+}
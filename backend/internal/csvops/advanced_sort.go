@@ -3,6 +3,7 @@ package csvops
 import (
 	"errors"
 	"fmt"
+	"net/netip"
 	"sort"
 	"strconv"
 	"strings"
@@ -16,24 +17,61 @@ import (
 
 type SortMode string
 type SortOrder string
+type NullPlacement string
 
 const (
 	SortAlpha   SortMode = "alphabetical"
 	SortNumeric SortMode = "numeric"
 	SortDate    SortMode = "date"
+	SortNatural SortMode = "natural" // embedded numbers compare by value: "item2" < "item10"
+	SortVersion SortMode = "version" // dotted/semantic versions: "1.9.0" < "1.10.0" < "2.0.0-rc.1" < "2.0.0"
+	SortIP      SortMode = "ip"      // IPv4/IPv6 addresses or CIDR prefixes, IPv4 first
+	SortLocale  SortMode = "locale"  // Unicode collation for Locale ("de", "fr", "sv"; "" => root order)
 
 	OrderAsc  SortOrder = "asc"
 	OrderDesc SortOrder = "desc"
+
+	// NullsAuto keeps the historical behaviour: unparsable numeric/date values go
+	// last in ascending and first in descending order; empty text sorts as "".
+	NullsAuto  NullPlacement = ""
+	NullsFirst NullPlacement = "first"
+	NullsLast  NullPlacement = "last"
+	NullsDrop  NullPlacement = "drop"  // leave such rows out of the result
+	NullsError NullPlacement = "error" // fail the table on the first such row
 )
 
+// maxInvalidReport caps the invalid values listed per table; InvalidCount has the total.
+const maxInvalidReport = 1000
+
+// SortKey is one level of a multi-key sort. Empty fields inherit the top-level options.
+type SortKey struct {
+	Key             string        `json:"key"`                        // column name or numeric index string
+	Mode            SortMode      `json:"mode,omitempty"`             // alphabetical | numeric | date | natural | version | ip | locale
+	Order           SortOrder     `json:"order,omitempty"`            // asc | desc
+	CaseInsensitive *bool         `json:"case_insensitive,omitempty"` // for alphabetical mode
+	DateFormat      string        `json:"date_format,omitempty"`      // optional explicit Go layout
+	Nulls           NullPlacement `json:"nulls,omitempty"`            // first | last | drop | error; "" => auto
+	Locale          string        `json:"locale,omitempty"`           // BCP 47 tag for locale mode
+}
+
 // request/response types
 type AdvancedSortOptions struct {
-	Mode            SortMode  `json:"mode"`             // alphabetical | numeric | date
-	Order           SortOrder `json:"order"`            // asc | desc
-	Key             string    `json:"key"`              // column name or numeric index string
-	TrimSpaces      bool      `json:"trim_spaces"`      // apply trimming before comparisons
-	CaseInsensitive bool      `json:"case_insensitive"` // for alphabetical mode
-	DateFormat      string    `json:"date_format"`      // optional explicit Go layout
+	Mode            SortMode      `json:"mode"`             // alphabetical | numeric | date | natural | version | ip | locale
+	Order           SortOrder     `json:"order"`            // asc | desc
+	Key             string        `json:"key"`              // column name or numeric index string
+	TrimSpaces      bool          `json:"trim_spaces"`      // apply trimming before comparisons
+	CaseInsensitive bool          `json:"case_insensitive"` // for alphabetical mode
+	DateFormat      string        `json:"date_format"`      // optional explicit Go layout
+	Nulls           NullPlacement `json:"nulls,omitempty"`  // empty/unparsable values: first | last | drop | error; "" => auto
+	Locale          string        `json:"locale,omitempty"` // BCP 47 tag for locale mode ("de", "fr", "sv")
+
+	// Keys sorts by several columns in priority order ("Country asc, then Revenue desc").
+	// When set, Key is ignored and Mode/Order/CaseInsensitive/DateFormat/Nulls/Locale act as defaults.
+	Keys []SortKey `json:"keys,omitempty"`
+
+	// streaming (AdvancedSortStream) only
+	MemoryBudgetMB int    `json:"memory_budget_mb,omitempty"` // rows held in memory before spilling a sorted run; 0 => 64
+	TempDir        string `json:"temp_dir,omitempty"`         // directory for run files; "" => system temp dir
 }
 
 type AdvancedSortRequest struct {
@@ -42,12 +80,22 @@ type AdvancedSortRequest struct {
 	Datasets  types.MultiDatasets `json:"datasets"`
 }
 
+// InvalidValue is a cell that did not parse for its sort mode (numeric, date, version, ip).
+type InvalidValue struct {
+	Row    int    `json:"row"` // 1-based data row number in the input
+	Column string `json:"column"`
+	Value  string `json:"value"`
+}
+
 type PerSortResult struct {
-	Name      string          `json:"name"`
-	Processed int             `json:"processed"`
-	Sorted    int             `json:"sorted"`
-	Result    types.TableData `json:"result"`
-	Error     *string         `json:"error"`
+	Name         string          `json:"name"`
+	Processed    int             `json:"processed"`
+	Sorted       int             `json:"sorted"`
+	Dropped      int             `json:"dropped"`       // rows left out by nulls=drop
+	InvalidCount int             `json:"invalid_count"` // unparsable cells, including ones past the report cap
+	Invalid      []InvalidValue  `json:"invalid,omitempty"`
+	Result       types.TableData `json:"result"`
+	Error        *string         `json:"error"`
 }
 
 type AdvancedSortResponse struct {
@@ -90,170 +138,315 @@ func parseDateGuess(s string, explicitLayout string) (time.Time, bool) {
 	return time.Time{}, false
 }
 
-// sortSingleTable sorts a single TableData according to options
-func sortSingleTable(tbl types.TableData, opts AdvancedSortOptions) (types.TableData, int, error) {
-	// resolve key index
-	idx, err := utils.ResolveKeyIndex(tbl, opts.Key)
-	if err != nil {
-		return types.TableData{}, 0, fmt.Errorf("key resolution: %w", err)
-	}
+// cellKey is the comparable form of one sort cell.
+type cellKey struct {
+	raw   string // cell used when neither value parses (case-folded/trimmed when case-insensitive)
+	alpha string // text, natural and locale (collation key) modes
+	num   float64
+	date  time.Time
+	ver   version
+	ip    netip.Prefix
+	ok    bool // numeric/date/version/ip value parsed
+	null  bool // empty (text modes) or unparsable (numeric/date/version/ip)
+}
 
-	// prepare rows copy
-	rows := make([][]string, 0, len(tbl.Rows))
-	for _, r := range tbl.Rows {
-		rows = append(rows, append([]string(nil), r...))
+// sortKey holds one cellKey per sort level.
+type sortKey []cellKey
+
+// sortLevel is one resolved sort key: the column plus the options that drive comparison.
+type sortLevel struct {
+	idx             int
+	column          string // header name (or index) for reports
+	mode            SortMode
+	order           SortOrder
+	trim            bool
+	caseInsensitive bool
+	dateFormat      string
+	nulls           NullPlacement
+	collator        *collator // locale mode only
+}
+
+// sortSpec is a resolved (possibly multi-key) sort. It is shared by the in-memory
+// and the external (spill-to-disk) sort.
+type sortSpec struct {
+	levels []sortLevel
+}
+
+// sortKeys returns the sort levels requested by opts; a request without Keys is a
+// single level built from the top-level options.
+func sortKeys(opts AdvancedSortOptions) []SortKey {
+	if len(opts.Keys) == 0 {
+		return []SortKey{{Key: opts.Key}}
 	}
-	processed := len(rows)
+	return opts.Keys
+}
 
-	// comparator uses extracted sort value per row
-	type rowWrap struct {
-		row      []string
-		alphaKey string
-		numKey   float64
-		numOk    bool
-		dateKey  time.Time
-		dateOk   bool
-	}
-
-	wrapped := make([]rowWrap, 0, len(rows))
-	for _, r := range rows {
-		w := rowWrap{row: r}
-		cell := ""
-		if idx < len(r) {
-			cell = r[idx]
+// newSortSpec resolves every sort key against the table header.
+func newSortSpec(tbl types.TableData, opts AdvancedSortOptions) (sortSpec, error) {
+	keys := sortKeys(opts)
+	spec := sortSpec{levels: make([]sortLevel, 0, len(keys))}
+	for _, k := range keys {
+		idx, err := utils.ResolveKeyIndex(tbl, k.Key)
+		if err != nil {
+			if len(keys) > 1 {
+				return sortSpec{}, fmt.Errorf("key resolution for '%s': %w", k.Key, err)
+			}
+			return sortSpec{}, fmt.Errorf("key resolution: %w", err)
 		}
-		if opts.TrimSpaces {
-			cell = strings.TrimSpace(cell)
+		lv := sortLevel{
+			idx:             idx,
+			column:          strconv.Itoa(idx),
+			mode:            opts.Mode,
+			order:           opts.Order,
+			trim:            opts.TrimSpaces,
+			caseInsensitive: boolOption(opts.CaseInsensitive, k.CaseInsensitive),
+			dateFormat:      opts.DateFormat,
+			nulls:           opts.Nulls,
 		}
-		switch opts.Mode {
-		case SortAlpha:
-			if opts.CaseInsensitive {
-				w.alphaKey = strings.ToLower(cell)
-			} else {
-				w.alphaKey = cell
-			}
-		case SortNumeric:
-			// parse float
-			if v, ok := tryParseFloat(cell); ok {
-				w.numKey = v
-				w.numOk = true
-			} else {
-				w.numOk = false
+		if tbl.HasHeader && idx < len(tbl.Header) {
+			lv.column = tbl.Header[idx]
+		}
+		if k.Mode != "" {
+			lv.mode = k.Mode
+		}
+		if k.Order != "" {
+			lv.order = k.Order
+		}
+		if k.DateFormat != "" {
+			lv.dateFormat = k.DateFormat
+		}
+		if k.Nulls != "" {
+			lv.nulls = k.Nulls
+		}
+		if lv.mode == SortLocale {
+			locale := opts.Locale
+			if k.Locale != "" {
+				locale = k.Locale
 			}
-		case SortDate:
-			if t, ok := parseDateGuess(cell, opts.DateFormat); ok {
-				w.dateKey = t
-				w.dateOk = true
-			} else {
-				w.dateOk = false
+			if lv.collator, err = newCollator(locale, lv.caseInsensitive); err != nil {
+				return sortSpec{}, err
 			}
 		}
-		wrapped = append(wrapped, w)
+		spec.levels = append(spec.levels, lv)
+	}
+	return spec, nil
+}
+
+// key extracts the sort key of a row.
+func (sp sortSpec) key(r []string) sortKey {
+	k := make(sortKey, len(sp.levels))
+	for i, lv := range sp.levels {
+		k[i] = lv.key(r)
+	}
+	return k
+}
+
+// compare orders two keys level by level: negative when a sorts first, positive
+// when b does, 0 on a tie (ties keep their input order).
+func (sp sortSpec) compare(a, b sortKey) int {
+	for i, lv := range sp.levels {
+		if c := lv.compare(a[i], b[i]); c != 0 {
+			return c
+		}
 	}
+	return 0
+}
 
-	// Define sort function
-	asc := opts.Order == OrderAsc
+// key extracts this level's cell key from a row.
+func (lv sortLevel) key(r []string) cellKey {
+	var k cellKey
+	cell := cellAt(r, lv.idx)
+	k.raw = cell
+	if lv.caseInsensitive {
+		k.raw = strings.ToLower(strings.TrimSpace(cell))
+	}
+	if lv.trim {
+		cell = strings.TrimSpace(cell)
+	}
+	switch lv.mode {
+	case SortAlpha, SortNatural:
+		if lv.caseInsensitive {
+			k.alpha = strings.ToLower(cell)
+		} else {
+			k.alpha = cell
+		}
+		k.null = strings.TrimSpace(cell) == ""
+	case SortLocale:
+		// the collator folds case itself when case-insensitive
+		k.alpha = lv.collator.key(cell)
+		k.null = strings.TrimSpace(cell) == ""
+	case SortNumeric:
+		// parse float
+		k.num, k.ok = tryParseFloat(cell)
+		k.null = !k.ok
+	case SortDate:
+		k.date, k.ok = parseDateGuess(cell, lv.dateFormat)
+		k.null = !k.ok
+	case SortVersion:
+		k.ver, k.ok = parseVersion(cell)
+		k.null = !k.ok
+	case SortIP:
+		k.ip, k.ok = parseIPKey(cell)
+		k.null = !k.ok
+	default:
+		// unknown mode -> compare raw cells
+		k.raw = cellAt(r, lv.idx)
+	}
+	return k
+}
 
-	sort.SliceStable(wrapped, func(i, j int) bool {
-		a := wrapped[i]
-		b := wrapped[j]
-		switch opts.Mode {
-		case SortAlpha:
-			ai := a.alphaKey
-			bi := b.alphaKey
-			if ai == bi {
-				// stable tie-breaker: preserve original order (SliceStable handles)
-				return false
-			}
-			if asc {
-				return ai < bi
-			}
-			return ai > bi
-		case SortNumeric:
-			// treat non-parsable values as greater-than for ascending (so they go to end)
-			// For descending, reverse behavior
-			if a.numOk && b.numOk {
-				if a.numKey == b.numKey {
-					return false
-				}
-				if asc {
-					return a.numKey < b.numKey
-				}
-				return a.numKey > b.numKey
-			}
-			// if only a is ok
-			if a.numOk && !b.numOk {
-				return asc // when asc, valid numeric comes before invalid -> true; when desc -> false
-			}
-			if !a.numOk && b.numOk {
-				return !asc
-			}
-			// both invalid: fallback to alphabetical compare on raw cell (trim/case handled earlier?)
-			ai := ""
-			bi := ""
-			if idx < len(a.row) {
-				ai = a.row[idx]
-			}
-			if idx < len(b.row) {
-				bi = b.row[idx]
-			}
-			if opts.CaseInsensitive {
-				ai = strings.ToLower(strings.TrimSpace(ai))
-				bi = strings.ToLower(strings.TrimSpace(bi))
-			}
-			if asc {
-				return ai < bi
-			}
-			return ai > bi
-		case SortDate:
-			// valid dates sort chronologically; invalid dates treated like numeric invalid values
-			if a.dateOk && b.dateOk {
-				if a.dateKey.Equal(b.dateKey) {
-					return false
-				}
-				if asc {
-					return a.dateKey.Before(b.dateKey)
-				}
-				return a.dateKey.After(b.dateKey)
-			}
-			if a.dateOk && !b.dateOk {
-				return asc
-			}
-			if !a.dateOk && b.dateOk {
-				return !asc
-			}
-			// both invalid: fallback to alpha
-			ai := ""
-			bi := ""
-			if idx < len(a.row) {
-				ai = a.row[idx]
-			}
-			if idx < len(b.row) {
-				bi = b.row[idx]
-			}
-			if opts.CaseInsensitive {
-				ai = strings.ToLower(strings.TrimSpace(ai))
-				bi = strings.ToLower(strings.TrimSpace(bi))
-			}
-			if asc {
-				return ai < bi
+// cellAt returns the cell at idx or "" when the row is short.
+func cellAt(r []string, idx int) string {
+	if idx < len(r) {
+		return r[idx]
+	}
+	return ""
+}
+
+// compare orders two cell keys of this level.
+func (lv sortLevel) compare(a, b cellKey) int {
+	// explicit null placement is independent of the order
+	if (lv.nulls == NullsFirst || lv.nulls == NullsLast) && a.null != b.null {
+		if a.null == (lv.nulls == NullsFirst) {
+			return -1
+		}
+		return 1
+	}
+
+	c := 0
+	switch lv.mode {
+	case SortAlpha, SortLocale:
+		c = strings.Compare(a.alpha, b.alpha)
+	case SortNatural:
+		c = naturalCompare(a.alpha, b.alpha)
+	case SortNumeric, SortDate, SortVersion, SortIP:
+		// valid values sort by value; non-parsable values are treated as greater than
+		// any valid value (end in ascending order, start in descending order)
+		switch {
+		case a.ok && b.ok:
+			switch lv.mode {
+			case SortNumeric:
+				c = cmpFloat(a.num, b.num)
+			case SortDate:
+				c = a.date.Compare(b.date)
+			case SortVersion:
+				c = compareVersion(a.ver, b.ver)
+			case SortIP:
+				c = compareIP(a.ip, b.ip)
 			}
-			return ai > bi
+		case a.ok:
+			c = -1
+		case b.ok:
+			c = 1
 		default:
-			// unknown mode -> fallback to alpha asc
-			ai := ""
-			bi := ""
-			if idx < len(a.row) {
-				ai = a.row[idx]
-			}
-			if idx < len(b.row) {
-				bi = b.row[idx]
+			// both invalid: fallback to alphabetical compare on raw cell
+			c = strings.Compare(a.raw, b.raw)
+		}
+	default:
+		c = strings.Compare(a.raw, b.raw)
+	}
+	if lv.order == OrderDesc {
+		return -c
+	}
+	return c
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// parses reports whether the level's mode parses cells (so a null is an invalid value).
+func (lv sortLevel) parses() bool {
+	switch lv.mode {
+	case SortNumeric, SortDate, SortVersion, SortIP:
+		return true
+	}
+	return false
+}
+
+// sortAudit collects the invalid values of one table while its rows are keyed and
+// applies the drop/error null placements.
+type sortAudit struct {
+	spec         sortSpec
+	invalid      []InvalidValue
+	invalidCount int
+	dropped      int
+}
+
+// admit records the null cells of a keyed row and reports whether the row is kept.
+// rowNum is the 1-based data row number.
+func (a *sortAudit) admit(rowNum int, row []string, k sortKey) (bool, error) {
+	keep := true
+	for i, lv := range a.spec.levels {
+		if !k[i].null {
+			continue
+		}
+		raw := cellAt(row, lv.idx)
+		if lv.parses() {
+			a.invalidCount++
+			if len(a.invalid) < maxInvalidReport {
+				a.invalid = append(a.invalid, InvalidValue{Row: rowNum, Column: lv.column, Value: raw})
 			}
-			if asc {
-				return ai < bi
+		}
+		switch lv.nulls {
+		case NullsError:
+			if strings.TrimSpace(raw) == "" {
+				return false, fmt.Errorf("row %d: empty value in column '%s'", rowNum, lv.column)
 			}
-			return ai > bi
+			return false, fmt.Errorf("row %d: invalid %s value '%s' in column '%s'", rowNum, lv.mode, raw, lv.column)
+		case NullsDrop:
+			keep = false
+		}
+	}
+	if !keep {
+		a.dropped++
+	}
+	return keep, nil
+}
+
+// report copies the audit counts onto a per-table result.
+func (a *sortAudit) report(pr *PerSortResult) {
+	pr.Dropped = a.dropped
+	pr.InvalidCount = a.invalidCount
+	pr.Invalid = a.invalid
+}
+
+// sortSingleTable sorts a single TableData according to options
+func sortSingleTable(tbl types.TableData, opts AdvancedSortOptions) (types.TableData, *sortAudit, error) {
+	// resolve key index
+	spec, err := newSortSpec(tbl, opts)
+	if err != nil {
+		return types.TableData{}, nil, err
+	}
+	audit := &sortAudit{spec: spec}
+
+	// comparator uses extracted sort value per row
+	type rowWrap struct {
+		row []string
+		key sortKey
+	}
+
+	wrapped := make([]rowWrap, 0, len(tbl.Rows))
+	for i, r := range tbl.Rows {
+		k := spec.key(r)
+		keep, err := audit.admit(i+1, r, k)
+		if err != nil {
+			return types.TableData{}, audit, err
 		}
+		if keep {
+			wrapped = append(wrapped, rowWrap{row: r, key: k})
+		}
+	}
+
+	// stable: equal keys preserve original order
+	sort.SliceStable(wrapped, func(i, j int) bool {
+		return spec.compare(wrapped[i].key, wrapped[j].key) < 0
 	})
 
 	// reconstruct rows
@@ -267,7 +460,7 @@ func sortSingleTable(tbl types.TableData, opts AdvancedSortOptions) (types.Table
 		Header:    append([]string(nil), tbl.Header...),
 		Rows:      sortedRows,
 	}
-	return out, processed, nil
+	return out, audit, nil
 }
 
 func tryParseFloat(s string) (float64, bool) {
@@ -286,6 +479,49 @@ func tryParseFloat(s string) (float64, bool) {
 	return 0, false
 }
 
+// validateSortOptions checks the required options and fills in defaults.
+func validateSortOptions(opts *AdvancedSortOptions) error {
+	if opts.Order == "" {
+		opts.Order = OrderAsc
+	}
+	for i, k := range sortKeys(*opts) {
+		if k.Mode == "" && opts.Mode == "" {
+			if len(opts.Keys) > 0 {
+				return fmt.Errorf("sort mode required for keys[%d]", i)
+			}
+			return errors.New("sort mode required")
+		}
+		if strings.TrimSpace(k.Key) == "" {
+			if len(opts.Keys) > 0 {
+				return fmt.Errorf("sort key required for keys[%d]", i)
+			}
+			return errors.New("sort key required")
+		}
+		nulls := opts.Nulls
+		if k.Nulls != "" {
+			nulls = k.Nulls
+		}
+		switch nulls {
+		case NullsAuto, NullsFirst, NullsLast, NullsDrop, NullsError:
+		default:
+			return fmt.Errorf("invalid nulls placement '%s' (expected first | last | drop | error)", nulls)
+		}
+		mode, locale := opts.Mode, opts.Locale
+		if k.Mode != "" {
+			mode = k.Mode
+		}
+		if k.Locale != "" {
+			locale = k.Locale
+		}
+		if mode == SortLocale {
+			if _, err := newCollator(locale, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // AdvancedSort sorts each table provided in datasets.Lists (or master if lists empty) with the given options.
 func AdvancedSort(req AdvancedSortRequest) (AdvancedSortResponse, error) {
 	var res AdvancedSortResponse
@@ -293,18 +529,10 @@ func AdvancedSort(req AdvancedSortRequest) (AdvancedSortResponse, error) {
 	start := time.Now()
 
 	// Validate options
-	if req.Options.Mode == "" {
-		msg := "sort mode required"
+	if err := validateSortOptions(&req.Options); err != nil {
+		msg := err.Error()
 		res.Error = &msg
-		return res, errors.New(msg)
-	}
-	if req.Options.Key == "" {
-		msg := "sort key required"
-		res.Error = &msg
-		return res, errors.New(msg)
-	}
-	if req.Options.Order == "" {
-		req.Options.Order = OrderAsc
+		return res, err
 	}
 
 	// determine tables to operate on
@@ -327,11 +555,17 @@ func AdvancedSort(req AdvancedSortRequest) (AdvancedSortResponse, error) {
 	perList := make([]PerSortResult, 0, len(tables))
 	totalProcessed := 0
 	totalSorted := 0
+	totalDropped := 0
+	totalInvalid := 0
 
 	for _, nt := range tables {
 		pr := PerSortResult{Name: nt.Name}
 		// sort the table
-		sorted, processed, err := sortSingleTable(nt.Table, req.Options)
+		sorted, audit, err := sortSingleTable(nt.Table, req.Options)
+		if audit != nil {
+			audit.report(&pr)
+			totalInvalid += pr.InvalidCount
+		}
 		if err != nil {
 			msg := err.Error()
 			pr.Error = &msg
@@ -339,11 +573,12 @@ func AdvancedSort(req AdvancedSortRequest) (AdvancedSortResponse, error) {
 			// continue to next table
 			continue
 		}
-		pr.Processed = processed
+		pr.Processed = len(nt.Table.Rows)
 		pr.Sorted = len(sorted.Rows)
 		pr.Result = sorted
-		totalProcessed += processed
+		totalProcessed += pr.Processed
 		totalSorted += pr.Sorted
+		totalDropped += pr.Dropped
 		perList = append(perList, pr)
 	}
 
@@ -352,6 +587,8 @@ func AdvancedSort(req AdvancedSortRequest) (AdvancedSortResponse, error) {
 		"tables_count":    len(perList),
 		"processed_total": totalProcessed,
 		"sorted_total":    totalSorted,
+		"dropped_total":   totalDropped,
+		"invalid_total":   totalInvalid,
 		"duration_ms":     int(time.Since(start).Milliseconds()),
 	}
 	res.Error = nil
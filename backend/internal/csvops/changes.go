@@ -0,0 +1,42 @@
+package csvops
+
+import "strconv"
+
+// Dry runs: FindAndReplace and DataClean can report the cells they would rewrite
+// instead of producing the rewritten table, so a rule set can be reviewed before it
+// is applied to a production list.
+
+// CellChange is one cell a dry run would rewrite.
+type CellChange struct {
+	Row    int      `json:"row"`    // 1-based data row number
+	Column string   `json:"column"` // header name, or the column index without a header
+	Old    string   `json:"old"`
+	New    string   `json:"new"`
+	Causes []string `json:"causes"` // rules ("rule 0") or transforms ("trim", "case_upper") that changed it, in order
+}
+
+// changeLog collects the changes of a dry run and decides which rows are written.
+type changeLog struct {
+	header      []string
+	changedRows bool // write the changed rows; otherwise none
+	changes     []CellChange
+}
+
+func newChangeLog(header []string, changedRows bool) *changeLog {
+	return &changeLog{header: header, changedRows: changedRows, changes: []CellChange{}}
+}
+
+// add records a change of column col in data row rowNum.
+func (l *changeLog) add(rowNum, col int, old, new string, causes []string) {
+	name := strconv.Itoa(col)
+	if col < len(l.header) {
+		name = l.header[col]
+	}
+	l.changes = append(l.changes, CellChange{Row: rowNum, Column: name, Old: old, New: new, Causes: causes})
+}
+
+// write reports whether a row is written to the output: always without a dry run,
+// and in a dry run only changed rows when they were asked for.
+func (l *changeLog) write(changed bool) bool {
+	return l == nil || (l.changedRows && changed)
+}
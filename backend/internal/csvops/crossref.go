@@ -2,6 +2,9 @@ package csvops
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"strconv"
 	"strings"
 	"time"
 
@@ -26,20 +29,79 @@ type CrossRefMultiRequest struct {
 }
 
 type CrossRefMultiOptions struct {
-	MatchMethod    MatchMethod `json:"match_method"` // exact | case_insensitive
+	MatchMethod    MatchMethod `json:"match_method"` // exact | case_insensitive | levenshtein | damerau | jaro_winkler | token_set | soundex | metaphone
 	MasterKey      string      `json:"master_key"`   // header name or numeric index string
 	DefaultListKey string      `json:"list_key"`     // fallback list key if per-list not provided
 	TrimSpaces     bool        `json:"trim_spaces"`
+
+	// Composite keys: rows match when every column matches. The list columns pair up
+	// with the master columns by position, so their names may differ.
+	MasterKeys      []string `json:"master_keys,omitempty"` // overrides MasterKey
+	DefaultListKeys []string `json:"list_keys,omitempty"`   // overrides DefaultListKey
+
+	// Fuzzy methods: minimum similarity in [0, 1] for a match (0 => 0.85; for the
+	// phonetic methods 0 accepts any candidate with the same code).
+	Threshold float64 `json:"threshold,omitempty"`
+
+	// Key normalizers applied to every key cell before matching, e.g. ["nfkc", "email"].
+	Normalizers []KeyNormalizer `json:"normalizers,omitempty"`
+
+	// Crossref only: what to do with list rows whose key is on several master rows.
+	Ambiguous AmbiguousPolicy `json:"ambiguous,omitempty"` // accept | mark | reject; "" => accept
+
+	// Crossref only: return every list row with a match_status column (and with
+	// master_row, the numbers of the master rows holding its key) instead of only
+	// the matched rows. The ambiguous policy then only decides the counts.
+	Annotate  bool `json:"annotate,omitempty"`
+	MasterRow bool `json:"master_row,omitempty"`
+
+	// Crossref streams only: report the keys on several rows of each streamed list.
+	// Off by default, as finding them keeps every list key in memory; in-memory
+	// lists always report them.
+	StreamDuplicates bool `json:"stream_duplicates,omitempty"`
+}
+
+// AmbiguousPolicy decides what happens to list rows whose key matches several master rows.
+type AmbiguousPolicy string
+
+const (
+	AmbiguousAccept AmbiguousPolicy = "accept" // count them as matches (default)
+	AmbiguousMark   AmbiguousPolicy = "mark"   // keep them; every result row gets an _ambiguous column
+	AmbiguousReject AmbiguousPolicy = "reject" // count them as missing
+)
+
+// AmbiguousColumn is appended to crossref results with the mark policy; it holds
+// "true" for rows whose key matched several master rows and "false" otherwise.
+const AmbiguousColumn = "_ambiguous"
+
+// Annotate mode columns and match_status values.
+const (
+	MatchStatusColumn = "match_status"
+	MasterRowColumn   = "master_row" // space-separated 1-based master row numbers
+
+	StatusMatched   = "matched"
+	StatusMissing   = "missing"
+	StatusAmbiguous = "ambiguous" // the key is on several master rows
+)
+
+// DuplicateKey reports a key that is on more than one row of a table.
+type DuplicateKey struct {
+	Key   string `json:"key"`   // key as it appears in the first row that has it
+	Count int    `json:"count"` // rows with the key
+	Rows  []int  `json:"rows"`  // 1-based data row numbers
 }
 
 // PerListResult returns stats and the matched rows for a single list.
 type PerListResult struct {
-	Name      string          `json:"name"`
-	Processed int             `json:"processed"`
-	Matched   int             `json:"matched"`
-	Missing   int             `json:"missing"`
-	Result    types.TableData `json:"result"`
-	Error     *string         `json:"error"`
+	Name       string          `json:"name"`
+	Processed  int             `json:"processed"`
+	Matched    int             `json:"matched"`
+	Missing    int             `json:"missing"`
+	Ambiguous  int             `json:"ambiguous"` // list rows whose key is on several master rows
+	Result     types.TableData `json:"result"`
+	Matches    []FuzzyMatch    `json:"matches,omitempty"`    // fuzzy methods: best candidate per list row (streams: see StreamTable.Scores)
+	Duplicates []DuplicateKey  `json:"duplicates,omitempty"` // keys on several list rows
+	Error      *string         `json:"error"`
 }
 
 // CrossRefMultiResponse contains per-list results and a small summary.
@@ -47,107 +109,401 @@ type CrossRefMultiResponse struct {
 	Operation string          `json:"operation"`
 	Summary   map[string]int  `json:"summary"`
 	PerList   []PerListResult `json:"per_list"`
-	Error     *string         `json:"error"`
+	// keys on several master rows
+	MasterDuplicates []DuplicateKey `json:"master_duplicates,omitempty"`
+	Error            *string        `json:"error"`
 }
 
-// CrossRefMulti compares the master key against each list and returns matched rows per list.
-// It does not merge results. Non-fatal list-level errors are reported in per_list[].error.
-func CrossRefMulti(req CrossRefMultiRequest) (CrossRefMultiResponse, error) {
-	var res CrossRefMultiResponse
-	res.Operation = req.Operation
-	start := time.Now()
+// masterKeysFor returns the master key columns (MasterKeys, else MasterKey).
+func masterKeysFor(opts CrossRefMultiOptions) []string {
+	if len(opts.MasterKeys) > 0 {
+		return opts.MasterKeys
+	}
+	if strings.TrimSpace(opts.MasterKey) == "" {
+		return nil
+	}
+	return []string{opts.MasterKey}
+}
+
+// listKeysFor determines the list key columns: per-list override -> default ->
+// master keys, preferring the composite form at each step.
+func listKeysFor(opts CrossRefMultiOptions, perList string, perListKeys []string) []string {
+	switch {
+	case len(perListKeys) > 0:
+		return perListKeys
+	case strings.TrimSpace(perList) != "":
+		return []string{perList}
+	case len(opts.DefaultListKeys) > 0:
+		return opts.DefaultListKeys
+	case opts.DefaultListKey != "":
+		return []string{opts.DefaultListKey}
+	}
+	return masterKeysFor(opts)
+}
+
+// resolveListKeys resolves the list key columns, which must pair up with the master's.
+func resolveListKeys(opts CrossRefMultiOptions, shape types.TableData, listKeys []string) ([]int, error) {
+	if n := len(masterKeysFor(opts)); len(listKeys) != n {
+		return nil, fmt.Errorf("list has %d key columns, master has %d", len(listKeys), n)
+	}
+	return utils.ResolveKeyIndices(shape, listKeys)
+}
+
+// keyFolding reports how keys are normalized before matching; fuzzy methods
+// always trim and fold case.
+func (o CrossRefMultiOptions) keyFolding() (trim bool, caseInsensitive bool) {
+	if o.MatchMethod.IsFuzzy() {
+		return true, true
+	}
+	return o.TrimSpaces, o.MatchMethod == MatchCaseInsensitive
+}
+
+// keyNormalizer returns the function that normalizes key cells: the normalizers,
+// then the folding of keyFolding.
+func (o CrossRefMultiOptions) keyNormalizer() (func(string) string, error) {
+	trim, caseInsensitive := o.keyFolding()
+	return newKeyNormalizer(o.Normalizers, trim, caseInsensitive)
+}
+
+// validateMatchOptions checks the match method and threshold.
+func validateMatchOptions(o CrossRefMultiOptions) error {
+	switch {
+	case o.MatchMethod == "", o.MatchMethod == MatchExact, o.MatchMethod == MatchCaseInsensitive, o.MatchMethod.IsFuzzy():
+	default:
+		return fmt.Errorf("unknown match method '%s'", o.MatchMethod)
+	}
+	if o.Threshold < 0 || o.Threshold > 1 {
+		return errors.New("threshold must be between 0 and 1")
+	}
+	return nil
+}
+
+// keyDisplay returns the key cells of row as they appear in the input.
+func keyDisplay(row []string, idxs []int) string {
+	parts := make([]string, len(idxs))
+	for i, idx := range idxs {
+		parts[i] = cellAt(row, idx)
+	}
+	return strings.Join(parts, " | ")
+}
+
+// hasKeyCells reports whether row is long enough to hold every key column;
+// master rows that are not have no key (a partial one would match empty cells).
+func hasKeyCells(row []string, idxs []int) bool {
+	for _, idx := range idxs {
+		if idx >= len(row) {
+			return false
+		}
+	}
+	return true
+}
+
+// keyTracker counts the rows of every key of a table and keeps the row numbers
+// of the keys found on more than one row.
+type keyTracker struct {
+	seen  map[string]keySeen
+	dups  map[string]*DuplicateKey
+	order []string // duplicated keys, in the order they were first repeated
+}
+
+type keySeen struct {
+	row     int
+	display string
+}
+
+func newKeyTracker(size int) *keyTracker {
+	return &keyTracker{seen: make(map[string]keySeen, size), dups: map[string]*DuplicateKey{}}
+}
 
+// add records that data row rowNum has the normalized key; display is the key as
+// it appears in the row.
+func (t *keyTracker) add(key string, rowNum int, display string) {
+	first, ok := t.seen[key]
+	if !ok {
+		t.seen[key] = keySeen{row: rowNum, display: display}
+		return
+	}
+	d := t.dups[key]
+	if d == nil {
+		d = &DuplicateKey{Key: first.display, Count: 1, Rows: []int{first.row}}
+		t.dups[key] = d
+		t.order = append(t.order, key)
+	}
+	d.Count++
+	d.Rows = append(d.Rows, rowNum)
+}
+
+// rows returns the row numbers of the rows with key.
+func (t *keyTracker) rows(key string) []int {
+	if d := t.dups[key]; d != nil {
+		return d.Rows
+	}
+	if first, ok := t.seen[key]; ok {
+		return []int{first.row}
+	}
+	return nil
+}
+
+// count returns the number of rows with key.
+func (t *keyTracker) count(key string) int {
+	if d := t.dups[key]; d != nil {
+		return d.Count
+	}
+	if _, ok := t.seen[key]; ok {
+		return 1
+	}
+	return 0
+}
+
+// report returns the duplicated keys (nil when there are none).
+func (t *keyTracker) report() []DuplicateKey {
+	var out []DuplicateKey
+	for _, k := range t.order {
+		out = append(out, *t.dups[k])
+	}
+	return out
+}
+
+// masterIndex answers list key lookups: the master keys with their row counts,
+// plus a fuzzyIndex for the fuzzy methods (whose keys are then tracked in the
+// fuzzy text form, so fuzzyIndex keys can be counted).
+type masterIndex struct {
+	normalize func(string) string
+	keys      *keyTracker
+	fuzzy     *fuzzyIndex
+}
+
+// buildMasterSet resolves the master key and indexes the normalized master keys.
+func buildMasterSet(req CrossRefMultiRequest) (*masterIndex, error) {
+	if err := validateMatchOptions(req.Options); err != nil {
+		return nil, err
+	}
+	switch req.Options.Ambiguous {
+	case "", AmbiguousAccept, AmbiguousMark, AmbiguousReject:
+	default:
+		return nil, fmt.Errorf("invalid ambiguous policy '%s' (expected accept | mark | reject)", req.Options.Ambiguous)
+	}
 	// validate master key presence
-	if strings.TrimSpace(req.Options.MasterKey) == "" {
-		msg := "master_key required"
-		res.Error = &msg
-		return res, errors.New(msg)
+	keys := masterKeysFor(req.Options)
+	if len(keys) == 0 {
+		return nil, errors.New("master_key required")
 	}
 
-	// resolve master key index
-	mKeyIdx, err := utils.ResolveKeyIndex(req.Datasets.Master, req.Options.MasterKey)
+	// resolve master key indices
+	mKeyIdxs, err := utils.ResolveKeyIndices(req.Datasets.Master, keys)
 	if err != nil {
-		msg := "master key resolution: " + err.Error()
-		res.Error = &msg
-		return res, err
+		return nil, errors.New("master key resolution: " + err.Error())
 	}
 
 	// build normalized master set
-	masterSet := make(map[string]struct{}, len(req.Datasets.Master.Rows))
-	for _, row := range req.Datasets.Master.Rows {
-		if mKeyIdx < 0 || mKeyIdx >= len(row) {
+	normalize, err := req.Options.keyNormalizer()
+	if err != nil {
+		return nil, err
+	}
+	ix := &masterIndex{normalize: normalize, keys: newKeyTracker(len(req.Datasets.Master.Rows))}
+	if req.Options.MatchMethod.IsFuzzy() {
+		ix.fuzzy = newFuzzyIndex(req.Options.MatchMethod, req.Options.Threshold)
+	}
+	for i, row := range req.Datasets.Master.Rows {
+		if !hasKeyCells(row, mKeyIdxs) {
 			continue
 		}
-		n := utils.Normalize(row[mKeyIdx], req.Options.TrimSpaces, req.Options.MatchMethod == MatchCaseInsensitive)
-		masterSet[n] = struct{}{}
+		n := utils.CompositeKey(row, mKeyIdxs, normalize)
+		display := keyDisplay(row, mKeyIdxs)
+		if ix.fuzzy != nil {
+			ix.fuzzy.add(n, display)
+			n = fuzzyText(n)
+		}
+		ix.keys.add(n, i+1, display)
+	}
+	if ix.fuzzy != nil {
+		ix.fuzzy.finish()
 	}
+	return ix, nil
+}
 
-	totalProcessed := 0
-	totalMatched := 0
-	perList := make([]PerListResult, 0, len(req.Datasets.Lists))
+// resultHeader returns the header of a crossref result for a list with header
+// whose rows are up to width cells wide.
+func (o CrossRefMultiOptions) resultHeader(header []string, width int) []string {
+	out := append([]string(nil), header...)
+	switch {
+	case o.Annotate:
+		out = append(padTo(out, width), MatchStatusColumn)
+		if o.MasterRow {
+			out = append(out, MasterRowColumn)
+		}
+	case o.Ambiguous == AmbiguousMark:
+		out = append(padTo(out, width), AmbiguousColumn)
+	}
+	return out
+}
 
-	// iterate each provided list
-	for _, named := range req.Datasets.Lists {
-		pl := PerListResult{Name: named.Name}
+// padTo pads row with empty cells up to n cells.
+func padTo(row []string, n int) []string {
+	for len(row) < n {
+		row = append(row, "")
+	}
+	return row
+}
 
-		// determine list key (per-list override -> default -> master key)
-		listKey := strings.TrimSpace(named.ListKey)
-		if listKey == "" {
-			listKey = req.Options.DefaultListKey
-		}
-		if listKey == "" {
-			listKey = req.Options.MasterKey
-		}
+// joinInts formats ns separated by sep.
+func joinInts(ns []int, sep string) string {
+	parts := make([]string, len(ns))
+	for i, n := range ns {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, sep)
+}
+
+// crossRefList matches every row of one list against the master set and writes the
+// matched rows to dst. shape supplies the list header used to resolve its key;
+// status columns are appended after width cells, so they line up on ragged rows.
+// With a fuzzy method, onMatch (if not nil) receives the best candidate of every row.
+// The keys on several list rows are reported only when dups is set.
+// Failures are reported in the returned PerListResult.Error.
+func crossRefList(opts CrossRefMultiOptions, ix *masterIndex, name string, listKeys []string, shape types.TableData, width int, src types.RowReader, dst types.RowWriter, onMatch func(FuzzyMatch) error, dups bool) PerListResult {
+	pl := PerListResult{Name: name}
+
+	// resolve indices for list
+	lKeyIdxs, err := resolveListKeys(opts, shape, listKeys)
+	if err != nil {
+		msg := "list key resolution: " + err.Error()
+		pl.Error = &msg
+		return pl
+	}
+	var seen *keyTracker
+	if dups {
+		seen = newKeyTracker(0)
+	}
 
-		// resolve index for list
-		lKeyIdx, err := utils.ResolveKeyIndex(named.Table, listKey)
+	for {
+		row, err := src.Next()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			msg := "list key resolution: " + err.Error()
+			msg := err.Error()
 			pl.Error = &msg
-			perList = append(perList, pl)
-			continue // skip this list but continue others
+			return pl
 		}
-
-		matches := [][]string{}
-		processed := 0
-		matched := 0
-		missing := 0
-
-		for _, row := range named.Table.Rows {
-			processed++
-			totalProcessed++
-			keyVal := ""
-			if lKeyIdx < len(row) {
-				keyVal = utils.Normalize(row[lKeyIdx], req.Options.TrimSpaces, req.Options.MatchMethod == MatchCaseInsensitive)
+		pl.Processed++
+		keyVal := utils.CompositeKey(row, lKeyIdxs, ix.normalize)
+		display := keyDisplay(row, lKeyIdxs)
+		if seen != nil {
+			seen.add(keyVal, pl.Processed, display)
+		}
+		// masterKey is the tracked master key the row matched ("" when none)
+		masterKey := keyVal
+		if ix.fuzzy != nil {
+			fm, id := ix.fuzzy.match(pl.Processed, keyVal, display)
+			if onMatch != nil {
+				if err := onMatch(fm); err != nil {
+					msg := err.Error()
+					pl.Error = &msg
+					return pl
+				}
 			}
-			if _, ok := masterSet[keyVal]; ok {
-				matched++
-				totalMatched++
-				// copy row to avoid aliasing
-				matches = append(matches, append([]string(nil), row...))
-			} else {
-				missing++
+			masterKey = ""
+			if id >= 0 {
+				masterKey = string(ix.fuzzy.keys[id])
 			}
 		}
+		count := 0
+		if ix.fuzzy == nil || masterKey != "" {
+			count = ix.keys.count(masterKey)
+		}
+		status := StatusMatched
+		switch {
+		case count == 0:
+			status = StatusMissing
+		case count > 1:
+			status = StatusAmbiguous
+			pl.Ambiguous++
+		}
+		accepted := count == 1 || (count > 1 && opts.Ambiguous != AmbiguousReject)
+		if accepted {
+			pl.Matched++
+		} else {
+			pl.Missing++
+		}
 
-		pl.Processed = processed
-		pl.Matched = matched
-		pl.Missing = missing
-		pl.Result = types.TableData{
-			HasHeader: named.Table.HasHeader,
-			Header:    append([]string(nil), named.Table.Header...),
-			Rows:      matches,
+		switch {
+		case opts.Annotate:
+			row = append(padTo(row, width), status)
+			if opts.MasterRow {
+				row = append(row, joinInts(ix.keys.rows(masterKey), " "))
+			}
+		case !accepted:
+			continue
+		case opts.Ambiguous == AmbiguousMark:
+			row = append(padTo(row, width), strconv.FormatBool(count > 1))
 		}
+		if err := dst.Write(row); err != nil {
+			msg := err.Error()
+			pl.Error = &msg
+			return pl
+		}
+	}
+	if seen != nil {
+		pl.Duplicates = seen.report()
+	}
+	return pl
+}
+
+// CrossRefMulti compares the master key against each list and returns matched rows per list
+// (every row, with status columns, in annotate mode). It does not merge results. Non-fatal list-level errors are reported in per_list[].error.
+func CrossRefMulti(req CrossRefMultiRequest) (CrossRefMultiResponse, error) {
+	var res CrossRefMultiResponse
+	res.Operation = req.Operation
+	start := time.Now()
+
+	index, err := buildMasterSet(req)
+	if err != nil {
+		msg := err.Error()
+		res.Error = &msg
+		return res, err
+	}
 
+	totalProcessed := 0
+	totalMatched := 0
+	totalAmbiguous := 0
+	perList := make([]PerListResult, 0, len(req.Datasets.Lists))
+
+	// iterate each provided list
+	for _, named := range req.Datasets.Lists {
+		// sliceRows copies each row, so matches never alias the input
+		matches := newRowCollector()
+		listKeys := listKeysFor(req.Options, named.ListKey, named.ListKeys)
+		width := tableWidth(named.Table)
+		var fuzzy []FuzzyMatch
+		keep := func(m FuzzyMatch) error {
+			fuzzy = append(fuzzy, m)
+			return nil
+		}
+		pl := crossRefList(req.Options, index, named.Name, listKeys, named.Table, width, &sliceRows{rows: named.Table.Rows}, matches, keep, true)
+		pl.Matches = fuzzy
+		if pl.Error == nil {
+			pl.Result = types.TableData{
+				HasHeader: named.Table.HasHeader,
+				Header:    req.Options.resultHeader(named.Table.Header, width),
+				Rows:      matches.rows,
+			}
+		}
+		totalProcessed += pl.Processed
+		totalMatched += pl.Matched
+		totalAmbiguous += pl.Ambiguous
 		perList = append(perList, pl)
 	}
 
 	// summary
+	res.MasterDuplicates = index.keys.report()
 	res.Summary = map[string]int{
-		"master_count":    len(req.Datasets.Master.Rows),
-		"lists_count":     len(req.Datasets.Lists),
-		"processed_total": totalProcessed,
-		"matched_total":   totalMatched,
+		"master_count":          len(req.Datasets.Master.Rows),
+		"master_duplicate_keys": len(res.MasterDuplicates),
+		"lists_count":           len(req.Datasets.Lists),
+		"processed_total":       totalProcessed,
+		"matched_total":         totalMatched,
+		"ambiguous_total":       totalAmbiguous,
 	}
 	res.PerList = perList
 	res.Error = nil
@@ -155,3 +511,81 @@ func CrossRefMulti(req CrossRefMultiRequest) (CrossRefMultiResponse, error) {
 	res.Summary["duration_ms"] = int(time.Since(start).Milliseconds())
 	return res, nil
 }
+
+// crossRefStreamList runs crossRefList for one streamed list. Rows are not known
+// in advance, so status columns go after the header, or for a headerless list
+// after its first row (read the list with a ragged pad/truncate mode when later
+// rows may be longer).
+func crossRefStreamList(opts CrossRefMultiOptions, ix *masterIndex, st StreamTable) PerListResult {
+	fail := func(msg string) PerListResult {
+		return PerListResult{Name: st.Name, Error: &msg}
+	}
+	if st.Input.Rows == nil || st.Output == nil {
+		return fail("input and output streams required")
+	}
+	shape, rows, err := peekFirst(st.Input)
+	if err != nil {
+		return fail(err.Error())
+	}
+	listKeys := listKeysFor(opts, st.ListKey, st.ListKeys)
+	if _, err := resolveListKeys(opts, shape, listKeys); err != nil {
+		return fail("list key resolution: " + err.Error())
+	}
+	width := tableWidth(shape)
+	header := opts.resultHeader(st.Input.Header, width)
+	if err := writeHeader(types.RowStream{HasHeader: st.Input.HasHeader, Header: header}, st.Output); err != nil {
+		return fail(err.Error())
+	}
+	var onMatch func(FuzzyMatch) error
+	if ix.fuzzy != nil && st.Scores != nil {
+		if err := st.Scores.Write(append([]string(nil), MatchScoresHeader...)); err != nil {
+			return fail(err.Error())
+		}
+		onMatch = func(m FuzzyMatch) error { return st.Scores.Write(m.Record()) }
+	}
+	pl := crossRefList(opts, ix, st.Name, listKeys, shape, width, rows, st.Output, onMatch, opts.StreamDuplicates)
+	pl.Result = types.TableData{HasHeader: st.Input.HasHeader, Header: header}
+	return pl
+}
+
+// CrossRefMultiStream is CrossRefMulti with the lists streamed (see StreamTable); the
+// master is still read from req.Datasets.Master and held in memory as a key set.
+func CrossRefMultiStream(req CrossRefMultiRequest, lists []StreamTable) (CrossRefMultiResponse, error) {
+	var res CrossRefMultiResponse
+	res.Operation = req.Operation
+	start := time.Now()
+
+	index, err := buildMasterSet(req)
+	if err != nil {
+		msg := err.Error()
+		res.Error = &msg
+		return res, err
+	}
+
+	totalProcessed := 0
+	totalMatched := 0
+	totalAmbiguous := 0
+	perList := make([]PerListResult, 0, len(lists))
+
+	for _, st := range lists {
+		pl := crossRefStreamList(req.Options, index, st)
+		totalProcessed += pl.Processed
+		totalMatched += pl.Matched
+		totalAmbiguous += pl.Ambiguous
+		perList = append(perList, pl)
+	}
+
+	res.MasterDuplicates = index.keys.report()
+	res.Summary = map[string]int{
+		"master_count":          len(req.Datasets.Master.Rows),
+		"master_duplicate_keys": len(res.MasterDuplicates),
+		"lists_count":           len(lists),
+		"processed_total":       totalProcessed,
+		"matched_total":         totalMatched,
+		"ambiguous_total":       totalAmbiguous,
+		"duration_ms":           int(time.Since(start).Milliseconds()),
+	}
+	res.PerList = perList
+	res.Error = nil
+	return res, nil
+}
@@ -0,0 +1,170 @@
+package csvops
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+)
+
+// Master rows too short for every key column have no key, so they must not match
+// list rows whose trailing key cells are empty.
+func TestShortMasterRowsHaveNoKey(t *testing.T) {
+	master := types.TableData{
+		HasHeader: true,
+		Header:    []string{"host", "site", "owner"},
+		Rows: [][]string{
+			{"alpha", "lon", "ann"},
+			{"beta"}, // too short for "site"
+			{"gamma", "", "cat"},
+		},
+	}
+	list := types.TableData{
+		HasHeader: true,
+		Header:    []string{"host", "site"},
+		Rows: [][]string{
+			{"alpha", "lon"},
+			{"beta", ""},
+			{"gamma", ""},
+		},
+	}
+	opts := CrossRefMultiOptions{MasterKeys: []string{"host", "site"}}
+	datasets := types.MultiDatasets{Master: master, Lists: []types.NamedTable{{Name: "list", Table: list}}}
+
+	tests := []struct {
+		name string
+		keys []string
+		want [][]string
+	}{
+		{"composite key", []string{"host", "site"}, [][]string{{"alpha", "lon"}, {"gamma", ""}}},
+		{"single key", []string{"host"}, [][]string{{"alpha", "lon"}, {"beta", ""}, {"gamma", ""}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := opts
+			o.MasterKeys = tt.keys
+			res, err := CrossRefMulti(CrossRefMultiRequest{Options: o, Datasets: datasets})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := res.PerList[0].Result.Rows; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("crossref rows = %v, want %v", got, tt.want)
+			}
+
+			jres, err := Join(JoinRequest{Options: JoinOptions{CrossRefMultiOptions: o, Type: JoinInner}, Datasets: datasets})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := jres.PerList[0].Result.Rows; len(got) != len(tt.want) {
+				t.Errorf("join returned %d rows, want %d: %v", len(got), len(tt.want), got)
+			}
+
+			sres, err := SetIntersection(SetOpRequest{Options: SetOpOptions{CrossRefMultiOptions: o}, Datasets: datasets})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sres.PerList[0].Result.Rows; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("set_intersection rows = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOneToManySkipsShortRows(t *testing.T) {
+	datasets := types.MultiDatasets{
+		Master: types.TableData{HasHeader: true, Header: []string{"host", "site"}, Rows: [][]string{{"beta"}}},
+		Lists: []types.NamedTable{{Name: "list", Table: types.TableData{
+			HasHeader: true,
+			Header:    []string{"host", "site"},
+			Rows:      [][]string{{"beta"}, {"beta", ""}},
+		}}},
+	}
+	res, err := OneToMany(OneToManyRequest{
+		Target: OneToManyTarget{
+			Keys: []string{"host", "site"},
+			LookupFrom: &LookupSource{
+				Table:   types.TableData{Rows: [][]string{{"beta", ""}}},
+				Columns: []string{"0", "1"},
+			},
+		},
+		Datasets: datasets,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.PerList[0].Matched; got != 0 {
+		t.Errorf("master matched %d short rows, want 0", got)
+	}
+	if got, want := res.PerList[1].Result.Rows, [][]string{{"beta", ""}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("list rows = %v, want %v", got, want)
+	}
+}
+
+// Streamed lists only track their duplicate keys when asked to, as that keeps
+// every list key in memory.
+func TestCrossRefStreamDuplicatesAreOptIn(t *testing.T) {
+	list := types.TableData{HasHeader: true, Header: []string{"host"}, Rows: [][]string{{"a"}, {"b"}, {"a"}}}
+	req := CrossRefMultiRequest{
+		Options:  CrossRefMultiOptions{MasterKey: "host"},
+		Datasets: types.MultiDatasets{Master: types.TableData{HasHeader: true, Header: []string{"host"}, Rows: [][]string{{"a"}}}},
+	}
+	for _, opt := range []bool{false, true} {
+		req.Options.StreamDuplicates = opt
+		res, err := CrossRefMultiStream(req, []StreamTable{{
+			Name:   "list",
+			Input:  types.RowStream{HasHeader: true, Header: list.Header, Rows: &sliceRows{rows: list.Rows}},
+			Output: newRowCollector(),
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []DuplicateKey
+		if opt {
+			want = []DuplicateKey{{Key: "a", Count: 2, Rows: []int{1, 3}}}
+		}
+		if got := res.PerList[0].Duplicates; !reflect.DeepEqual(got, want) {
+			t.Errorf("stream_duplicates=%v: duplicates = %v, want %v", opt, got, want)
+		}
+	}
+}
+
+func TestStatusColumnsLineUpOnRaggedHeaderlessLists(t *testing.T) {
+	master := types.TableData{Rows: [][]string{{"a"}, {"b"}, {"b"}}}
+	list := types.TableData{Rows: [][]string{{"a"}, {"b", "x", "y"}, {"c", "z"}}}
+	datasets := types.MultiDatasets{Master: master, Lists: []types.NamedTable{{Name: "list", Table: list}}}
+
+	tests := []struct {
+		name string
+		opts CrossRefMultiOptions
+		want [][]string
+	}{
+		{
+			"annotate",
+			CrossRefMultiOptions{MasterKey: "0", Annotate: true, MasterRow: true},
+			[][]string{
+				{"a", "", "", StatusMatched, "1"},
+				{"b", "x", "y", StatusAmbiguous, "2 3"},
+				{"c", "z", "", StatusMissing, ""},
+			},
+		},
+		{
+			"mark",
+			CrossRefMultiOptions{MasterKey: "0", Ambiguous: AmbiguousMark},
+			[][]string{
+				{"a", "", "", "false"},
+				{"b", "x", "y", "true"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := CrossRefMulti(CrossRefMultiRequest{Options: tt.opts, Datasets: datasets})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := res.PerList[0].Result.Rows; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package csvops
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 	"unicode"
@@ -27,6 +29,8 @@ type DataCleanOptions struct {
 	CaseMode        CaseMode `json:"case_mode"`         // none|upper|lower|title
 	Columns         []string `json:"columns,omitempty"` // columns to apply; empty == all columns
 	CaseInsensitive bool     `json:"case_insensitive"`  // used when resolving header names (not for converting)
+	DryRun          bool     `json:"dry_run"`           // return the changes instead of the cleaned tables
+	ChangedRows     bool     `json:"changed_rows"`      // dry_run: Result holds the cleaned changed rows
 }
 
 type PerCleanResult struct {
@@ -34,6 +38,7 @@ type PerCleanResult struct {
 	Processed int             `json:"processed"` // rows processed
 	Modified  int             `json:"modified"`  // number of cells changed
 	Result    types.TableData `json:"result"`
+	Changes   []CellChange    `json:"changes,omitempty"` // dry_run: every cell the transforms would rewrite
 	Error     *string         `json:"error"`
 }
 
@@ -138,63 +143,99 @@ func resolveColumnsToIndices(tbl types.TableData, cols []string, caseInsensitive
 }
 
 // applyTransforms applies trimming/case transforms to a single cell according to options.
-// returns (newVal, changed)
-func applyTransforms(cell string, opts DataCleanOptions) (string, bool) {
-	orig := cell
+// It returns the new value and the transforms that changed it ("trim", "collapse_ws",
+// "case_<mode>"), none when the cell is unchanged.
+func applyTransforms(cell string, opts DataCleanOptions) (string, []string) {
+	var causes []string
+	step := func(name, v string) {
+		if v != cell {
+			cell = v
+			causes = append(causes, name)
+		}
+	}
 	if opts.TrimSpaces {
-		cell = strings.TrimSpace(cell)
+		step("trim", strings.TrimSpace(cell))
 	}
 	if opts.CollapseInnerWS {
-		cell = collapseInnerWhitespace(cell)
+		step("collapse_ws", collapseInnerWhitespace(cell))
 	}
 	switch opts.CaseMode {
 	case CaseUpper:
-		cell = strings.ToUpper(cell)
+		step("case_upper", strings.ToUpper(cell))
 	case CaseLower:
-		cell = strings.ToLower(cell)
+		step("case_lower", strings.ToLower(cell))
 	case CaseTitle:
-		cell = toTitleCase(cell)
+		step("case_title", toTitleCase(cell))
 	}
-	return cell, cell != orig
+	return cell, causes
 }
 
-// processSingleTable runs cleaning ops on a single table and returns modified table + counts.
-func processSingleTable(tbl types.TableData, opts DataCleanOptions) (types.TableData, int, int, error) {
-	indices, err := resolveColumnsToIndices(tbl, opts.Columns, opts.CaseInsensitive)
+// cleanRows applies the transforms to the selected columns of every row of src and
+// writes the rows to dst. shape (header and, for headerless tables, the first row)
+// is used to resolve the columns. It returns the processed rows and modified cells.
+// With a change log (dry run) the changes are recorded and only the changed rows
+// written, if they were asked for.
+func cleanRows(shape types.TableData, src types.RowReader, dst types.RowWriter, opts DataCleanOptions, log *changeLog) (int, int, error) {
+	indices, err := resolveColumnsToIndices(shape, opts.Columns, opts.CaseInsensitive)
 	if err != nil {
-		return types.TableData{}, 0, 0, err
+		return 0, 0, err
 	}
-	processedRows := len(tbl.Rows)
+	processedRows := 0
 	modifiedCells := 0
 
-	// deep copy rows to avoid mutating input
-	outRows := make([][]string, 0, len(tbl.Rows))
-	for _, r := range tbl.Rows {
-		rowCopy := append([]string(nil), r...)
+	for {
+		row, err := src.Next()
+		if err == io.EOF {
+			return processedRows, modifiedCells, nil
+		}
+		if err != nil {
+			return processedRows, modifiedCells, err
+		}
+		processedRows++
+		changed := false
 		for _, colIdx := range indices {
 			// ensure column exists for this row (if shorter, consider as empty cell; extend?)
-			if colIdx >= len(rowCopy) {
+			if colIdx >= len(row) {
 				// if row shorter than header, pad with empty strings up to colIdx
-				needed := colIdx - len(rowCopy) + 1
+				needed := colIdx - len(row) + 1
 				for i := 0; i < needed; i++ {
-					rowCopy = append(rowCopy, "")
+					row = append(row, "")
 				}
 			}
-			newVal, changed := applyTransforms(rowCopy[colIdx], opts)
-			if changed {
+			newVal, causes := applyTransforms(row[colIdx], opts)
+			if newVal != row[colIdx] {
 				modifiedCells++
-				rowCopy[colIdx] = newVal
+				changed = true
+				if log != nil {
+					log.add(processedRows, colIdx, row[colIdx], newVal, causes)
+				}
+				row[colIdx] = newVal
 			}
 		}
-		outRows = append(outRows, rowCopy)
+		if !log.write(changed) {
+			continue
+		}
+		if err := dst.Write(row); err != nil {
+			return processedRows, modifiedCells, err
+		}
+	}
+}
+
+// processSingleTable runs cleaning ops on a single table and returns modified table + counts.
+func processSingleTable(tbl types.TableData, opts DataCleanOptions, log *changeLog) (types.TableData, int, int, error) {
+	// sliceRows hands out row copies, so the input table is never modified
+	out := newRowCollector()
+	processedRows, modifiedCells, err := cleanRows(tbl, &sliceRows{rows: tbl.Rows}, out, opts, log)
+	if err != nil {
+		return types.TableData{}, 0, 0, err
 	}
 
-	out := types.TableData{
+	result := types.TableData{
 		HasHeader: tbl.HasHeader,
 		Header:    append([]string(nil), tbl.Header...),
-		Rows:      outRows,
+		Rows:      out.rows,
 	}
-	return out, processedRows, modifiedCells, nil
+	return result, processedRows, modifiedCells, nil
 }
 
 // DataClean executes cleaning operations across master and/or lists.
@@ -228,7 +269,11 @@ func DataClean(req DataCleanRequest) (DataCleanResponse, error) {
 
 	for _, nt := range tables {
 		pl := PerCleanResult{Name: nt.Name}
-		outTbl, processed, modified, err := processSingleTable(nt.Table, req.Options)
+		var log *changeLog
+		if req.Options.DryRun {
+			log = newChangeLog(nt.Table.Header, req.Options.ChangedRows)
+		}
+		outTbl, processed, modified, err := processSingleTable(nt.Table, req.Options, log)
 		if err != nil {
 			msg := err.Error()
 			pl.Error = &msg
@@ -238,6 +283,62 @@ func DataClean(req DataCleanRequest) (DataCleanResponse, error) {
 		pl.Processed = processed
 		pl.Modified = modified
 		pl.Result = outTbl
+		if log != nil {
+			pl.Changes = log.changes
+		}
+		perList = append(perList, pl)
+		totalProcessed += processed
+		totalModified += modified
+	}
+
+	res.PerList = perList
+	res.Summary = map[string]int{
+		"tables_count":    len(perList),
+		"processed_total": totalProcessed,
+		"modified_total":  totalModified,
+		"duration_ms":     int(time.Since(start).Milliseconds()),
+	}
+	res.Error = nil
+	return res, nil
+}
+
+// DataCleanStream is DataClean over row streams (see StreamTable). A dry run writes
+// the header and changed rows only with changed_rows.
+func DataCleanStream(req DataCleanRequest, tables []StreamTable) (DataCleanResponse, error) {
+	var res DataCleanResponse
+	res.Operation = req.Operation
+	start := time.Now()
+
+	if req.Options.CaseMode == "" {
+		req.Options.CaseMode = CaseNone
+	}
+
+	perList := make([]PerCleanResult, 0, len(tables))
+	totalProcessed := 0
+	totalModified := 0
+
+	for _, st := range tables {
+		pl := PerCleanResult{Name: st.Name}
+		var log *changeLog
+		if req.Options.DryRun {
+			log = newChangeLog(st.Input.Header, req.Options.ChangedRows)
+		}
+		processed, modified, err := cleanStreamTable(st, req.Options, log)
+		if err != nil {
+			msg := err.Error()
+			pl.Error = &msg
+			perList = append(perList, pl)
+			continue
+		}
+		pl.Processed = processed
+		pl.Modified = modified
+		pl.Result = types.TableData{
+			HasHeader: st.Input.HasHeader,
+			Header:    append([]string(nil), st.Input.Header...),
+		}
+		if log != nil {
+			pl.Changes = log.changes
+		}
 		perList = append(perList, pl)
 		totalProcessed += processed
 		totalModified += modified
@@ -253,3 +354,25 @@ func DataClean(req DataCleanRequest) (DataCleanResponse, error) {
 	res.Error = nil
 	return res, nil
 }
+
+// cleanStreamTable cleans a single streamed table into its Output.
+func cleanStreamTable(st StreamTable, opts DataCleanOptions, log *changeLog) (int, int, error) {
+	if st.Input.Rows == nil || st.Output == nil {
+		return 0, 0, errors.New("input and output streams required")
+	}
+	shape, rows, err := peekFirst(st.Input)
+	if err != nil {
+		return 0, 0, err
+	}
+	// resolve columns before anything is written
+	if _, err := resolveColumnsToIndices(shape, opts.Columns, opts.CaseInsensitive); err != nil {
+		return 0, 0, err
+	}
+	// a dry run without changed_rows writes nothing, not even the header
+	if log.write(true) {
+		if err := writeHeader(st.Input, st.Output); err != nil {
+			return 0, 0, err
+		}
+	}
+	return cleanRows(shape, rows, st.Output, opts, log)
+}
@@ -0,0 +1,116 @@
+package csvops
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// operationFunc decodes a raw JSON request and runs the operation it belongs to.
+type operationFunc func(raw json.RawMessage) (interface{}, error)
+
+// decodeAndRun adapts a typed operation to the raw JSON form used by Dispatch.
+func decodeAndRun[Req any, Resp any](fn func(Req) (Resp, error)) operationFunc {
+	return func(raw json.RawMessage) (interface{}, error) {
+		var req Req
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return nil, fmt.Errorf("decode request: %w", err)
+		}
+		return fn(req)
+	}
+}
+
+// operations maps the request "operation" field to its implementation.
+var operations = map[string]operationFunc{
+	"crossref":         decodeAndRun(CrossRefMulti),
+	"data_clean":       decodeAndRun(DataClean),
+	"advanced_sort":    decodeAndRun(AdvancedSort),
+	"advanced_extract": decodeAndRun(AdvancedExtract),
+	"find_replace":     decodeAndRun(FindAndReplace),
+	"one_to_many":      decodeAndRun(OneToMany),
+	"many_to_one":      decodeAndRun(ManyToOne),
+	"join":             decodeAndRun(Join),
+	"traverse":         decodeAndRun(Traverse),
+
+	string(SetOpDifference):          decodeAndRun(SetDifference),
+	string(SetOpIntersection):        decodeAndRun(SetIntersection),
+	string(SetOpUnion):               decodeAndRun(SetUnion),
+	string(SetOpSymmetricDifference): decodeAndRun(SymmetricDifference),
+}
+
+// operationAliases lets requests use the CLI command names as well.
+var operationAliases = map[string]string{
+	"crossref_multi": "crossref",
+	"clean":          "data_clean",
+	"sort":           "advanced_sort",
+	"extract":        "advanced_extract",
+	"replace":        "find_replace",
+	"one-to-many":    "one_to_many",
+	"many-to-one":    "many_to_one",
+	"link_analysis":  "traverse",
+	"difference":     string(SetOpDifference),
+	"intersection":   string(SetOpIntersection),
+	"union":          string(SetOpUnion),
+	"symdiff":        string(SetOpSymmetricDifference),
+}
+
+// dispatchError is the response emitted when a request never reaches an operation.
+type dispatchError struct {
+	Operation string  `json:"operation"`
+	Error     *string `json:"error"`
+}
+
+// Operations returns the canonical operation names accepted by Dispatch.
+func Operations() []string {
+	names := make([]string, 0, len(operations))
+	for name := range operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Dispatch decodes the "operation" field of raw, runs the matching operation and
+// returns its response encoded as JSON. The response is returned even when the
+// operation fails, so callers can always emit it; the error mirrors response.error.
+func Dispatch(raw json.RawMessage) (json.RawMessage, error) {
+	var head struct {
+		Operation string `json:"operation"`
+	}
+	if err := json.Unmarshal(raw, &head); err != nil {
+		return errorJSON("", "invalid request: "+err.Error())
+	}
+
+	name := strings.ToLower(strings.TrimSpace(head.Operation))
+	if name == "" {
+		return errorJSON("", "operation required")
+	}
+	if canonical, ok := operationAliases[name]; ok {
+		name = canonical
+	}
+	run, ok := operations[name]
+	if !ok {
+		return errorJSON(head.Operation, fmt.Sprintf("unknown operation '%s'. available operations: [%s]", head.Operation, strings.Join(Operations(), ", ")))
+	}
+
+	resp, opErr := run(raw)
+	if resp == nil {
+		return errorJSON(head.Operation, opErr.Error())
+	}
+	out, err := json.Marshal(resp)
+	if err != nil {
+		return nil, fmt.Errorf("encode response: %w", err)
+	}
+	return out, opErr
+}
+
+// errorJSON builds a dispatchError response and returns it alongside the error.
+func errorJSON(operation, msg string) (json.RawMessage, error) {
+	out, err := json.Marshal(dispatchError{Operation: operation, Error: &msg})
+	if err != nil {
+		return nil, err
+	}
+	return out, errors.New(msg)
+}
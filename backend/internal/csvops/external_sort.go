@@ -0,0 +1,456 @@
+package csvops
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+)
+
+// External sort: rows are buffered until the memory budget is reached, each full
+// buffer is sorted and spilled to a temporary run file, and the runs are k-way
+// merged into the destination. Every row carries its input ordinal so equal keys
+// keep their input order across runs, exactly like the in-memory stable sort.
+
+const (
+	defaultSortMemoryMB = 64
+	// maxMergeFanIn bounds the number of run files open at once; more runs are
+	// merged in several passes.
+	maxMergeFanIn = 128
+	// rowOverhead approximates the per-row and per-cell bookkeeping in memory.
+	rowOverhead  = 64
+	cellOverhead = 16
+)
+
+// sortedRow is a row with its input ordinal and extracted sort key.
+type sortedRow struct {
+	ord uint64
+	row []string
+	key sortKey
+}
+
+// externalSorter holds the state of one external sort.
+type externalSorter struct {
+	spec    sortSpec
+	budget  int64
+	tempDir string
+	runs    []string // run files waiting to be merged, oldest first
+	files   []string // every file created, removed by cleanup
+	spilled int      // number of runs written (including intermediate merge passes)
+}
+
+// externalSort sorts every row of src admitted by audit into dst using at most
+// roughly budget bytes of row data in memory. It returns the number of rows read
+// and of runs spilled to disk.
+func externalSort(audit *sortAudit, budget int64, tempDir string, src types.RowReader, dst types.RowWriter) (int, int, error) {
+	if budget <= 0 {
+		budget = defaultSortMemoryMB << 20
+	}
+	spec := audit.spec
+	es := &externalSorter{spec: spec, budget: budget, tempDir: tempDir}
+	defer es.cleanup()
+
+	var buf []sortedRow
+	var used int64
+	var ord uint64
+	for {
+		row, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return int(ord), es.spilled, err
+		}
+		key := spec.key(row)
+		ord++
+		keep, err := audit.admit(int(ord), row, key)
+		if err != nil {
+			return int(ord), es.spilled, err
+		}
+		if !keep {
+			continue
+		}
+		buf = append(buf, sortedRow{ord: ord, row: row, key: key})
+		used += rowSize(row)
+		if used >= es.budget {
+			if err := es.spill(buf); err != nil {
+				return int(ord), es.spilled, err
+			}
+			buf = nil
+			used = 0
+		}
+	}
+
+	// everything fit in one buffer: no disk involved
+	if len(es.runs) == 0 {
+		es.sortBuffer(buf)
+		for _, sr := range buf {
+			if err := dst.Write(sr.row); err != nil {
+				return int(ord), 0, err
+			}
+		}
+		return int(ord), 0, nil
+	}
+
+	if len(buf) > 0 {
+		if err := es.spill(buf); err != nil {
+			return int(ord), es.spilled, err
+		}
+	}
+	// reduce the run count until a single merge can stream into dst
+	for len(es.runs) > maxMergeFanIn {
+		if err := es.mergePass(); err != nil {
+			return int(ord), es.spilled, err
+		}
+	}
+	err := es.merge(es.runs, func(sr sortedRow) error { return dst.Write(sr.row) })
+	return int(ord), es.spilled, err
+}
+
+// rowSize estimates the memory held by a buffered row.
+func rowSize(row []string) int64 {
+	n := int64(rowOverhead)
+	for _, c := range row {
+		n += int64(len(c)) + cellOverhead
+	}
+	return n
+}
+
+func (es *externalSorter) sortBuffer(buf []sortedRow) {
+	sort.SliceStable(buf, func(i, j int) bool {
+		return es.spec.compare(buf[i].key, buf[j].key) < 0
+	})
+}
+
+// spill sorts buf and writes it to a new run file.
+func (es *externalSorter) spill(buf []sortedRow) error {
+	es.sortBuffer(buf)
+	w, err := es.newRun()
+	if err != nil {
+		return err
+	}
+	for _, sr := range buf {
+		if err := w.write(sr); err != nil {
+			w.close()
+			return err
+		}
+	}
+	if err := w.close(); err != nil {
+		return err
+	}
+	es.runs = append(es.runs, w.path)
+	return nil
+}
+
+// mergePass merges the oldest runs in groups of maxMergeFanIn into new runs.
+func (es *externalSorter) mergePass() error {
+	var next []string
+	for len(es.runs) > 0 {
+		n := len(es.runs)
+		if n > maxMergeFanIn {
+			n = maxMergeFanIn
+		}
+		group := es.runs[:n]
+		es.runs = es.runs[n:]
+
+		w, err := es.newRun()
+		if err != nil {
+			return err
+		}
+		next = append(next, w.path)
+		if err := es.merge(group, w.write); err != nil {
+			w.close()
+			return err
+		}
+		if err := w.close(); err != nil {
+			return err
+		}
+		for _, p := range group {
+			os.Remove(p)
+		}
+	}
+	es.runs = next
+	return nil
+}
+
+// merge k-way merges the given runs, calling emit for each row in sorted order.
+func (es *externalSorter) merge(paths []string, emit func(sortedRow) error) error {
+	h := &runHeap{spec: es.spec}
+	defer func() {
+		for _, rh := range h.runs {
+			rh.reader.close()
+		}
+	}()
+	for _, p := range paths {
+		rr, err := openRun(p)
+		if err != nil {
+			return err
+		}
+		sr, err := rr.read(es.spec)
+		if err == io.EOF {
+			rr.close()
+			continue
+		}
+		if err != nil {
+			rr.close()
+			return err
+		}
+		heap.Push(h, runHead{reader: rr, row: sr})
+	}
+
+	for h.Len() > 0 {
+		top := &h.runs[0]
+		if err := emit(top.row); err != nil {
+			return err
+		}
+		next, err := top.reader.read(es.spec)
+		if err == io.EOF {
+			heap.Pop(h).(runHead).reader.close()
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		top.row = next
+		heap.Fix(h, 0)
+	}
+	return nil
+}
+
+func (es *externalSorter) cleanup() {
+	for _, p := range es.files {
+		os.Remove(p)
+	}
+}
+
+// runHead is an open run with its current (smallest unmerged) row.
+type runHead struct {
+	reader *runReader
+	row    sortedRow
+}
+
+// runHeap orders open runs by their current row (key, then input ordinal).
+type runHeap struct {
+	spec sortSpec
+	runs []runHead
+}
+
+func (h *runHeap) Len() int { return len(h.runs) }
+
+func (h *runHeap) Less(i, j int) bool {
+	a, b := h.runs[i].row, h.runs[j].row
+	if c := h.spec.compare(a.key, b.key); c != 0 {
+		return c < 0
+	}
+	return a.ord < b.ord
+}
+
+func (h *runHeap) Swap(i, j int) { h.runs[i], h.runs[j] = h.runs[j], h.runs[i] }
+
+func (h *runHeap) Push(x interface{}) { h.runs = append(h.runs, x.(runHead)) }
+
+func (h *runHeap) Pop() interface{} {
+	n := len(h.runs) - 1
+	rh := h.runs[n]
+	h.runs = h.runs[:n]
+	return rh
+}
+
+// Run files store rows in a length-prefixed binary form (ordinal, cell count, then
+// each cell as length + bytes) so every cell round-trips byte for byte.
+
+type runWriter struct {
+	path string
+	f    *os.File
+	w    *bufio.Writer
+	tmp  [binary.MaxVarintLen64]byte
+}
+
+// newRun creates a run file and registers it for cleanup.
+func (es *externalSorter) newRun() (*runWriter, error) {
+	f, err := os.CreateTemp(es.tempDir, "csvops-sort-*.run")
+	if err != nil {
+		return nil, fmt.Errorf("create sort run: %w", err)
+	}
+	es.files = append(es.files, f.Name())
+	es.spilled++
+	return &runWriter{path: f.Name(), f: f, w: bufio.NewWriterSize(f, 1<<20)}, nil
+}
+
+func (rw *runWriter) uvarint(v uint64) error {
+	n := binary.PutUvarint(rw.tmp[:], v)
+	_, err := rw.w.Write(rw.tmp[:n])
+	return err
+}
+
+func (rw *runWriter) write(sr sortedRow) error {
+	if err := rw.uvarint(sr.ord); err != nil {
+		return err
+	}
+	if err := rw.uvarint(uint64(len(sr.row))); err != nil {
+		return err
+	}
+	for _, c := range sr.row {
+		if err := rw.uvarint(uint64(len(c))); err != nil {
+			return err
+		}
+		if _, err := rw.w.WriteString(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rw *runWriter) close() error {
+	err := rw.w.Flush()
+	if cerr := rw.f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("write sort run: %w", err)
+	}
+	return nil
+}
+
+type runReader struct {
+	f *os.File
+	r *bufio.Reader
+}
+
+func openRun(path string) (*runReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open sort run: %w", err)
+	}
+	return &runReader{f: f, r: bufio.NewReaderSize(f, 1<<16)}, nil
+}
+
+// read returns the next row of the run (with its key recomputed), or io.EOF.
+func (rr *runReader) read(spec sortSpec) (sortedRow, error) {
+	ord, err := binary.ReadUvarint(rr.r)
+	if err == io.EOF {
+		return sortedRow{}, io.EOF
+	}
+	if err != nil {
+		return sortedRow{}, fmt.Errorf("read sort run: %w", err)
+	}
+	n, err := binary.ReadUvarint(rr.r)
+	if err != nil {
+		return sortedRow{}, fmt.Errorf("read sort run: %w", unexpectedEOF(err))
+	}
+	row := make([]string, n)
+	for i := range row {
+		l, err := binary.ReadUvarint(rr.r)
+		if err != nil {
+			return sortedRow{}, fmt.Errorf("read sort run: %w", unexpectedEOF(err))
+		}
+		b := make([]byte, l)
+		if _, err := io.ReadFull(rr.r, b); err != nil {
+			return sortedRow{}, fmt.Errorf("read sort run: %w", unexpectedEOF(err))
+		}
+		row[i] = string(b)
+	}
+	return sortedRow{ord: ord, row: row, key: spec.key(row)}, nil
+}
+
+func (rr *runReader) close() {
+	rr.f.Close()
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// AdvancedSortStream sorts each streamed table (see StreamTable) with bounded memory,
+// spilling sorted runs to Options.TempDir and merging them; the order is AdvancedSort's.
+func AdvancedSortStream(req AdvancedSortRequest, tables []StreamTable) (AdvancedSortResponse, error) {
+	var res AdvancedSortResponse
+	res.Operation = req.Operation
+	start := time.Now()
+
+	if err := validateSortOptions(&req.Options); err != nil {
+		msg := err.Error()
+		res.Error = &msg
+		return res, err
+	}
+	if len(tables) == 0 {
+		msg := "no tables provided"
+		res.Error = &msg
+		return res, errors.New(msg)
+	}
+
+	budget := int64(req.Options.MemoryBudgetMB) << 20
+	perList := make([]PerSortResult, 0, len(tables))
+	totalProcessed := 0
+	totalSorted := 0
+	totalDropped := 0
+	totalInvalid := 0
+	totalRuns := 0
+
+	for _, st := range tables {
+		pr := PerSortResult{Name: st.Name}
+		processed, audit, runs, err := sortStreamTable(st, req.Options, budget)
+		totalRuns += runs
+		if audit != nil {
+			audit.report(&pr)
+			totalInvalid += pr.InvalidCount
+		}
+		if err != nil {
+			msg := err.Error()
+			pr.Error = &msg
+			perList = append(perList, pr)
+			continue
+		}
+		pr.Processed = processed
+		pr.Sorted = processed - pr.Dropped
+		pr.Result = types.TableData{
+			HasHeader: st.Input.HasHeader,
+			Header:    append([]string(nil), st.Input.Header...),
+		}
+		totalProcessed += processed
+		totalSorted += pr.Sorted
+		totalDropped += pr.Dropped
+		perList = append(perList, pr)
+	}
+
+	res.PerList = perList
+	res.Summary = map[string]int{
+		"tables_count":    len(perList),
+		"processed_total": totalProcessed,
+		"sorted_total":    totalSorted,
+		"dropped_total":   totalDropped,
+		"invalid_total":   totalInvalid,
+		"spilled_runs":    totalRuns,
+		"duration_ms":     int(time.Since(start).Milliseconds()),
+	}
+	res.Error = nil
+	return res, nil
+}
+
+// sortStreamTable externally sorts one streamed table into its Output. The audit
+// is returned even when sorting fails so invalid values can still be reported.
+func sortStreamTable(st StreamTable, opts AdvancedSortOptions, budget int64) (int, *sortAudit, int, error) {
+	if st.Input.Rows == nil || st.Output == nil {
+		return 0, nil, 0, errors.New("input and output streams required")
+	}
+	spec, err := newSortSpec(types.TableData{HasHeader: st.Input.HasHeader, Header: st.Input.Header}, opts)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	if err := writeHeader(st.Input, st.Output); err != nil {
+		return 0, nil, 0, err
+	}
+	audit := &sortAudit{spec: spec}
+	rows, runs, err := externalSort(audit, budget, opts.TempDir, st.Input.Rows, st.Output)
+	return rows, audit, runs, err
+}
@@ -0,0 +1,93 @@
+package csvops
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+)
+
+func TestExternalSortMatchesInMemorySort(t *testing.T) {
+	header := []string{"group", "n", "id"}
+	var rows [][]string
+	for i := 0; i < 300; i++ {
+		// few distinct keys so equal keys are spread over many runs
+		rows = append(rows, []string{fmt.Sprintf("g%d", (i*7)%5), fmt.Sprint((i * 13) % 11), fmt.Sprint(i)})
+	}
+
+	tests := []struct {
+		name     string
+		opts     AdvancedSortOptions
+		budget   int64
+		wantRuns int
+	}{
+		{"in memory", AdvancedSortOptions{Key: "group", Mode: SortAlpha, Order: OrderAsc}, 1 << 20, 0},
+		{"one row per run", AdvancedSortOptions{Key: "group", Mode: SortAlpha, Order: OrderAsc}, 1, len(rows) + (len(rows)+maxMergeFanIn-1)/maxMergeFanIn},
+		{"small runs", AdvancedSortOptions{Key: "group", Mode: SortAlpha, Order: OrderDesc}, 2000, -1},
+		{"multi key", AdvancedSortOptions{Mode: SortAlpha, Order: OrderAsc, Keys: []SortKey{{Key: "n", Mode: SortNumeric}, {Key: "group", Order: OrderDesc}}}, 2000, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tbl := types.TableData{HasHeader: true, Header: header, Rows: rows}
+			if err := validateSortOptions(&tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			want, _, err := sortSingleTable(tbl, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			spec, err := newSortSpec(tbl, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := newRowCollector()
+			n, runs, err := externalSort(&sortAudit{spec: spec}, tt.budget, t.TempDir(), &sliceRows{rows: rows}, got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != len(rows) {
+				t.Errorf("read %d rows, want %d", n, len(rows))
+			}
+			switch {
+			case tt.wantRuns >= 0 && runs != tt.wantRuns:
+				t.Errorf("spilled %d runs, want %d", runs, tt.wantRuns)
+			case tt.wantRuns < 0 && runs < 2:
+				t.Errorf("spilled %d runs, want several", runs)
+			}
+			if !reflect.DeepEqual(got.rows, want.Rows) {
+				t.Errorf("external order differs from in-memory order\ngot  %v\nwant %v", ids(got.rows), ids(want.Rows))
+			}
+		})
+	}
+}
+
+func TestExternalSortKeepsInputOrderOfEqualKeys(t *testing.T) {
+	rows := [][]string{{"b", "1"}, {"a", "2"}, {"b", "3"}, {"a", "4"}, {"b", "5"}, {"a", "6"}}
+	tbl := types.TableData{Rows: rows}
+	spec, err := newSortSpec(tbl, AdvancedSortOptions{Key: "0", Mode: SortAlpha, Order: OrderAsc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := newRowCollector()
+	// a budget of one byte spills every row to its own run
+	if _, runs, err := externalSort(&sortAudit{spec: spec}, 1, t.TempDir(), &sliceRows{rows: rows}, got); err != nil {
+		t.Fatal(err)
+	} else if runs != len(rows) {
+		t.Fatalf("spilled %d runs, want %d", runs, len(rows))
+	}
+	want := []string{"2", "4", "6", "1", "3", "5"}
+	if g := ids(got.rows); !reflect.DeepEqual(g, want) {
+		t.Errorf("order = %v, want %v", g, want)
+	}
+}
+
+// ids returns the last cell of every row.
+func ids(rows [][]string) []string {
+	out := make([]string, len(rows))
+	for i, r := range rows {
+		out[i] = r[len(r)-1]
+	}
+	return out
+}
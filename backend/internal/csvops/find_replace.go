@@ -3,27 +3,48 @@ package csvops
 import (
 	"errors"
 	"fmt"
+	"io"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
 	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/utils"
 )
 
+// ReplaceMode selects how the targets of a rule are matched.
+type ReplaceMode string
+
+const (
+	ReplaceLiteral ReplaceMode = "literal" // targets match verbatim
+	ReplaceRegex   ReplaceMode = "regex"   // targets are regular expressions; the replacement may use $1 or ${name}
+	ReplaceGlob    ReplaceMode = "glob"    // * matches any run of characters, ? any single character
+)
+
 // ReplaceRule defines a mapping from multiple target variants to one replacement.
 type ReplaceRule struct {
-	Targets         []string `json:"targets"`                    // e.g. ["USA", "U.S.", "United States of America"]
-	Replacement     string   `json:"replacement"`                // e.g. "USA"
-	CaseInsensitive *bool    `json:"case_insensitive,omitempty"` // nil => use global option default
-	WholeCell       *bool    `json:"whole_cell,omitempty"`       // nil => default false (substring replace)
+	Targets         []string        `json:"targets"`                    // e.g. ["USA", "U.S.", "United States of America"]
+	Replacement     string          `json:"replacement"`                // e.g. "USA"
+	Mode            ReplaceMode     `json:"mode,omitempty"`             // "" => literal
+	CaseInsensitive *bool           `json:"case_insensitive,omitempty"` // nil => use global option default
+	WholeCell       *bool           `json:"whole_cell,omitempty"`       // nil => default false (substring replace)
+	Where           *ConditionGroup `json:"where,omitempty"`            // only rows matching this filter; nil => every row
 }
 
 // FindReplaceOptions configures the operation behavior.
 type FindReplaceOptions struct {
-	TrimSpaces      bool     `json:"trim_spaces"`       // trim cell before matching (and when doing whole-cell compare)
+	TrimSpaces      bool     `json:"trim_spaces"`       // trim cell before matching (and when doing whole-cell compare); the whitespace is kept
+	TrimOutput      bool     `json:"trim_output"`       // trim every selected cell written, changed or not
 	CaseInsensitive bool     `json:"case_insensitive"`  // default for rules where rule.CaseInsensitive==nil
 	Columns         []string `json:"columns,omitempty"` // columns to apply; empty => all columns
+	DryRun          bool     `json:"dry_run"`           // return the changes instead of the rewritten table
+	ChangedRows     bool     `json:"changed_rows"`      // dry_run: Result holds the rewritten changed rows
+
+	// WhereOptions are the extract options of the where filters (trim_spaces,
+	// case_insensitive, date_format). The options above only affect the finding.
+	WhereOptions AdvancedExtractOptions `json:"where_options"`
 }
 
 // Request / response
@@ -32,13 +53,16 @@ type FindReplaceRequest struct {
 	Options   FindReplaceOptions `json:"options"`
 	Dataset   types.TableData    `json:"dataset"`
 	Rules     []ReplaceRule      `json:"rules"`
+	Mapping   *ReplaceMapping    `json:"mapping,omitempty"` // expanded into rules after Rules
+	Where     *ConditionGroup    `json:"where,omitempty"`   // rows not matching are left untouched; nil => every row
 }
 
 type FindReplaceRuleResult struct {
 	Index        int      `json:"index"` // rule index
 	Targets      []string `json:"targets"`
 	Replacement  string   `json:"replacement"`
-	Replacements int      `json:"replacements"` // how many replacements applied (occurrences or cells changed)
+	Replacements int      `json:"replacements"`    // how many replacements applied (occurrences or cells changed)
+	Error        *string  `json:"error,omitempty"` // the rule was invalid and skipped
 }
 
 type FindReplaceResponse struct {
@@ -46,6 +70,8 @@ type FindReplaceResponse struct {
 	Summary   types.ResultSummary     `json:"summary"`
 	Result    types.TableData         `json:"result"`
 	PerRule   []FindReplaceRuleResult `json:"per_rule"`
+	Mapping   []MappingIssue          `json:"mapping_issues,omitempty"` // conflicts and cycles in the mapping table
+	Changes   []CellChange            `json:"changes,omitempty"`        // dry_run: every cell the rules would rewrite
 	Error     *string                 `json:"error"`
 }
 
@@ -53,13 +79,27 @@ type FindReplaceResponse struct {
 // If wholeCell==true it anchors ^(?:a|b|c)$
 // If wholeCell==false it builds (?:a|b|c) (to match substrings)
 // caseInsensitive toggles the (?i) flag via prefix.
-func buildRegexForRule(targets []string, wholeCell bool, caseInsensitive bool) (*regexp.Regexp, error) {
+// Literal targets are quoted, regex targets used as they are (their capture groups
+// are numbered across targets in order) and glob targets translated.
+func buildRegexForRule(targets []string, mode ReplaceMode, wholeCell bool, caseInsensitive bool) (*regexp.Regexp, error) {
 	if len(targets) == 0 {
 		return nil, errors.New("empty targets")
 	}
 	parts := make([]string, 0, len(targets))
-	for _, t := range targets {
-		parts = append(parts, regexp.QuoteMeta(t))
+	for i, t := range targets {
+		switch mode {
+		case "", ReplaceLiteral:
+			parts = append(parts, regexp.QuoteMeta(t))
+		case ReplaceRegex:
+			if _, err := regexp.Compile(t); err != nil {
+				return nil, fmt.Errorf("target %d: %w", i, err)
+			}
+			parts = append(parts, t)
+		case ReplaceGlob:
+			parts = append(parts, globToRegex(t))
+		default:
+			return nil, fmt.Errorf("unknown mode '%s' (want literal, regex or glob)", mode)
+		}
 	}
 	pat := "(?:" + strings.Join(parts, "|") + ")"
 	if wholeCell {
@@ -75,6 +115,73 @@ func buildRegexForRule(targets []string, wholeCell bool, caseInsensitive bool) (
 	return re, nil
 }
 
+// globToRegex translates a glob pattern: * matches any run of characters, ? any
+// single character and everything else itself.
+func globToRegex(glob string) string {
+	var b strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return b.String()
+}
+
+// checkGroupRefs reports replacement references ($1, ${name}) to capture groups
+// re does not have; regexp.Expand would silently replace them with nothing.
+func checkGroupRefs(re *regexp.Regexp, replacement string) error {
+	names := map[string]bool{}
+	for _, n := range re.SubexpNames() {
+		if n != "" {
+			names[n] = true
+		}
+	}
+	for i := 0; i < len(replacement); i++ {
+		if replacement[i] != '$' || i+1 == len(replacement) {
+			continue
+		}
+		rest := replacement[i+1:]
+		if rest[0] == '$' {
+			i++
+			continue
+		}
+		var name string
+		if rest[0] == '{' {
+			end := strings.IndexByte(rest, '}')
+			if end < 0 {
+				continue
+			}
+			name = rest[1:end]
+		} else {
+			end := strings.IndexFunc(rest, func(r rune) bool {
+				return !(r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+			})
+			if end < 0 {
+				end = len(rest)
+			}
+			name = rest[:end]
+		}
+		if name == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(name); err == nil {
+			if n > re.NumSubexp() {
+				return fmt.Errorf("replacement references group $%s but the targets have %d", name, re.NumSubexp())
+			}
+			continue
+		}
+		if !names[name] {
+			return fmt.Errorf("replacement references unknown group '%s' (use ${1}x for a group followed by text)", name)
+		}
+	}
+	return nil
+}
+
 // resolveColumnsToIndices is reused from data_clean.go (it's in same package).
 // If Columns empty => all columns (based on header length or first row length).
 // It leverages utils.ParseIndexString for numeric indices.
@@ -123,41 +230,79 @@ func resolveColumnsToIndicesForReplace(tbl types.TableData, cols []string) ([]in
 	return indices, nil
 }
 
-// FindAndReplace performs the smart find/replace on a single dataset (no multi-list support).
-func FindAndReplace(req FindReplaceRequest) (FindReplaceResponse, error) {
-	var res FindReplaceResponse
-	res.Operation = req.Operation
-	start := time.Now()
+// compiledRule is a ReplaceRule with its regex built and defaults resolved.
+// Invalid rules keep their error and a nil re and are skipped.
+type compiledRule struct {
+	rule       ReplaceRule
+	re         *regexp.Regexp
+	caseInRule bool
+	wholeCell  bool
+	expand     bool // the replacement references capture groups (regex mode)
+	where      *ConditionGroup
+	err        error
+}
 
-	// validation
-	if req.Dataset.Rows == nil {
-		msg := "dataset required"
-		res.Error = &msg
-		return res, errors.New(msg)
+// replace applies the rule to cell and returns the result and the number of
+// replacements (at most 1 for whole-cell rules).
+func (cr compiledRule) replace(cell string) (string, int) {
+	matches := cr.re.FindAllStringSubmatchIndex(cell, -1)
+	if len(matches) == 0 {
+		return cell, 0
 	}
-	if len(req.Rules) == 0 {
-		msg := "no rules provided"
-		res.Error = &msg
-		return res, errors.New(msg)
+	var b []byte
+	last := 0
+	for _, m := range matches {
+		b = append(b, cell[last:m[0]]...)
+		if cr.expand {
+			b = cr.re.ExpandString(b, cr.rule.Replacement, cell, m)
+		} else {
+			b = append(b, cr.rule.Replacement...)
+		}
+		last = m[1]
 	}
+	b = append(b, cell[last:]...)
+	return string(b), len(matches)
+}
 
+// replacer applies the compiled rules to the selected columns of one row at a time.
+type replacer struct {
+	indices  []int
+	compiled []compiledRule
+	trim     bool  // match against the trimmed cell
+	trimOut  bool  // write the cells trimmed
+	counts   []int // per-rule replacement counts
+	valid    int   // rules that compiled
+	rows     int   // rows seen, for the change log
+	log      *changeLog
+
+	// where filters (AdvancedExtract semantics), evaluated against the row as read
+	where     *ConditionGroup
+	headerMap map[string]int
+	condOpts  AdvancedExtractOptions
+	active    []bool // per rule: its where filter matches the current row
+}
+
+// newReplacer resolves the columns against the table shape and compiles every rule.
+func newReplacer(req FindReplaceRequest, shape types.TableData) (*replacer, error) {
 	// resolve columns
-	indices, err := resolveColumnsToIndicesForReplace(req.Dataset, req.Options.Columns)
+	indices, err := resolveColumnsToIndicesForReplace(shape, req.Options.Columns)
 	if err != nil {
-		msg := err.Error()
-		res.Error = &msg
-		return res, err
+		return nil, err
 	}
 
-	// compile regexes for each rule
-	type compiledRule struct {
-		rule       ReplaceRule
-		re         *regexp.Regexp
-		caseInRule bool
-		wholeCell  bool
+	// the row filter and the rule filters share the header map
+	where := ConditionGroup{}
+	if req.Where != nil {
+		where = *req.Where
+	}
+	headerMap, err := filterHeaderMap(where, shape.Header)
+	if err != nil {
+		return nil, fmt.Errorf("where: %w", err)
 	}
 
+	// compile regexes for each rule; invalid rules are reported per rule
 	compiled := make([]compiledRule, 0, len(req.Rules))
+	valid := 0
 	for _, r := range req.Rules {
 		ci := req.Options.CaseInsensitive
 		if r.CaseInsensitive != nil {
@@ -167,107 +312,242 @@ func FindAndReplace(req FindReplaceRequest) (FindReplaceResponse, error) {
 		if r.WholeCell != nil {
 			wc = *r.WholeCell
 		}
-		re, err := buildRegexForRule(r.Targets, wc, ci)
-		if err != nil {
-			msg := fmt.Sprintf("rule compile error: %v", err)
-			res.Error = &msg
-			return res, err
-		}
-		compiled = append(compiled, compiledRule{
+		cr := compiledRule{
 			rule:       r,
-			re:         re,
 			caseInRule: ci,
 			wholeCell:  wc,
-		})
+			expand:     r.Mode == ReplaceRegex && strings.Contains(r.Replacement, "$"),
+			where:      r.Where,
+		}
+		re, err := buildRegexForRule(r.Targets, r.Mode, wc, ci)
+		if err == nil && cr.expand {
+			err = checkGroupRefs(re, r.Replacement)
+		}
+		if err == nil && r.Where != nil {
+			if _, err = filterHeaderMap(*r.Where, shape.Header); err != nil {
+				err = fmt.Errorf("where: %w", err)
+			}
+		}
+		if err != nil {
+			cr.err = err
+		} else {
+			cr.re = re
+			valid++
+		}
+		compiled = append(compiled, cr)
 	}
 
-	// Prepare output table copy
-	outRows := make([][]string, 0, len(req.Dataset.Rows))
-	for _, r := range req.Dataset.Rows {
-		outRows = append(outRows, append([]string(nil), r...))
+	rp := &replacer{
+		indices:   indices,
+		compiled:  compiled,
+		trim:      req.Options.TrimSpaces,
+		trimOut:   req.Options.TrimOutput,
+		counts:    make([]int, len(compiled)),
+		valid:     valid,
+		where:     req.Where,
+		headerMap: headerMap,
+		condOpts:  req.Options.WhereOptions,
+		active:    make([]bool, len(compiled)),
+	}
+	if req.Options.DryRun {
+		rp.log = newChangeLog(shape.Header, req.Options.ChangedRows)
 	}
+	return rp, nil
+}
 
-	// per-rule counters
-	perRuleCounts := make([]int, len(compiled))
+// matches evaluates a where filter against row; a nil filter matches every row.
+func (rp *replacer) matches(where *ConditionGroup, row []string) (bool, error) {
+	if where == nil {
+		return true, nil
+	}
+	return evalGroup(*where, row, rp.headerMap, rp.condOpts)
+}
 
-	// Apply rules: iterate rows, columns, rules (rules applied in order)
-	for ri, row := range outRows {
-		for _, colIdx := range indices {
-			// ensure column exists; if not, pad row
-			if colIdx >= len(row) {
-				needed := colIdx - len(row) + 1
-				for i := 0; i < needed; i++ {
-					row = append(row, "")
-				}
-				outRows[ri] = row
-			}
-			cell := row[colIdx]
-			origCell := cell
+// applyRow applies the rules (in order) to the selected cells of row, padding the
+// row when a selected column is missing. It modifies row in place and returns it
+// and whether a cell changed. Rows outside the where filters are left as they are.
+func (rp *replacer) applyRow(row []string) ([]string, bool, error) {
+	rp.rows++
+	if ok, err := rp.matches(rp.where, row); err != nil || !ok {
+		return row, false, err
+	}
+	for i, cr := range rp.compiled {
+		if cr.re == nil {
+			continue
+		}
+		ok, err := rp.matches(cr.where, row)
+		if err != nil {
+			return row, false, fmt.Errorf("rule %d where: %w", i, err)
+		}
+		rp.active[i] = ok
+	}
 
-			// pre-trim if requested (affects matching)
-			if req.Options.TrimSpaces {
-				cell = strings.TrimSpace(cell)
+	changed := false
+	for _, colIdx := range rp.indices {
+		// ensure column exists; if not, pad row
+		if colIdx >= len(row) {
+			needed := colIdx - len(row) + 1
+			for i := 0; i < needed; i++ {
+				row = append(row, "")
 			}
+		}
+		origCell := row[colIdx]
 
-			// apply rules sequentially
-			modifiedCell := cell
-			for i, cr := range compiled {
-				// if wholeCell: match entire cell; if matched => replace whole cell
-				if cr.wholeCell {
-					if cr.re.MatchString(modifiedCell) {
-						// replace whole cell with replacement
-						modifiedCell = cr.rule.Replacement
-						perRuleCounts[i] += 1 // count cell change once
-						// note: don't break; subsequent rules may also operate on the new value
-					}
-					continue
-				}
+		// match against the trimmed cell if requested and put the whitespace back
+		// afterwards, so replacements land at their original offsets
+		cell, lead, trail := origCell, "", ""
+		if rp.trim {
+			cell = strings.TrimSpace(origCell)
+			lead = origCell[:len(origCell)-len(strings.TrimLeftFunc(origCell, unicode.IsSpace))]
+			trail = origCell[len(lead)+len(cell):]
+		}
 
-				// substring replacement: use ReplaceAllStringFunc to count occurrences
-				if cr.re.MatchString(modifiedCell) {
-					count := 0
-					newVal := cr.re.ReplaceAllStringFunc(modifiedCell, func(_ string) string {
-						count++
-						return cr.rule.Replacement
-					})
-					if count > 0 {
-						perRuleCounts[i] += count
-						modifiedCell = newVal
-					}
+		// apply rules sequentially
+		var causes []string
+		modifiedCell := cell
+		for i, cr := range rp.compiled {
+			if cr.re == nil || !rp.active[i] {
+				continue
+			}
+			// whole-cell rules are anchored, so they match (and count) the cell once;
+			// don't break: subsequent rules may also operate on the new value
+			newVal, count := cr.replace(modifiedCell)
+			if count > 0 {
+				rp.counts[i] += count
+				modifiedCell = newVal
+				if rp.log != nil {
+					causes = append(causes, "rule "+strconv.Itoa(i))
 				}
 			}
+		}
 
-			// If trimmed earlier but original input had different whitespace and we don't want to lose it
-			// we preserve trimmed value (user asked trimming only for matching). We'll set cell to modifiedCell.
-			// If the caller didn't want trimming, they'd set TrimSpaces=false.
-			if modifiedCell != origCell {
-				outRows[ri][colIdx] = modifiedCell
+		if rp.trimOut {
+			if trimmed := strings.TrimSpace(modifiedCell); trimmed != modifiedCell {
+				modifiedCell = trimmed
+				if rp.log != nil {
+					causes = append(causes, "trim")
+				}
+			}
+		} else {
+			modifiedCell = lead + modifiedCell + trail
+		}
+		if modifiedCell != origCell {
+			row[colIdx] = modifiedCell
+			changed = true
+			if rp.log != nil {
+				rp.log.add(rp.rows, colIdx, origCell, modifiedCell, causes)
 			}
 		}
 	}
+	return row, changed, nil
+}
 
-	// build per-rule results
-	perRuleRes := make([]FindReplaceRuleResult, len(compiled))
+// replaceRows runs rp over every row of src and writes the results to dst (in a
+// dry run only the changed rows, if any).
+func replaceRows(rp *replacer, src types.RowReader, dst types.RowWriter) (int, error) {
+	processed := 0
+	for {
+		row, err := src.Next()
+		if err == io.EOF {
+			return processed, nil
+		}
+		if err != nil {
+			return processed, err
+		}
+		processed++
+		row, changed, err := rp.applyRow(row)
+		if err != nil {
+			return processed, fmt.Errorf("row %d: %w", processed, err)
+		}
+		if !rp.log.write(changed) {
+			continue
+		}
+		if err := dst.Write(row); err != nil {
+			return processed, err
+		}
+	}
+}
+
+// ruleResults builds the per-rule results and the total replacement count.
+func (rp *replacer) ruleResults() ([]FindReplaceRuleResult, int) {
+	perRuleRes := make([]FindReplaceRuleResult, len(rp.compiled))
 	totalReplacements := 0
-	for i, cr := range compiled {
+	for i, cr := range rp.compiled {
 		perRuleRes[i] = FindReplaceRuleResult{
 			Index:        i,
 			Targets:      cr.rule.Targets,
 			Replacement:  cr.rule.Replacement,
-			Replacements: perRuleCounts[i],
+			Replacements: rp.counts[i],
 		}
-		totalReplacements += perRuleCounts[i]
+		if cr.err != nil {
+			msg := cr.err.Error()
+			perRuleRes[i].Error = &msg
+		}
+		totalReplacements += rp.counts[i]
 	}
+	return perRuleRes, totalReplacements
+}
+
+// FindAndReplace performs the smart find/replace on a single dataset (no multi-list support).
+func FindAndReplace(req FindReplaceRequest) (FindReplaceResponse, error) {
+	var res FindReplaceResponse
+	res.Operation = req.Operation
+	start := time.Now()
+
+	// validation
+	if req.Dataset.Rows == nil {
+		msg := "dataset required"
+		res.Error = &msg
+		return res, errors.New(msg)
+	}
+	issues, err := expandMapping(&req)
+	res.Mapping = issues
+	if err != nil {
+		msg := err.Error()
+		res.Error = &msg
+		return res, err
+	}
+	if len(req.Rules) == 0 {
+		msg := "no rules provided"
+		res.Error = &msg
+		return res, errors.New(msg)
+	}
+
+	rp, err := newReplacer(req, req.Dataset)
+	if err != nil {
+		msg := err.Error()
+		res.Error = &msg
+		return res, err
+	}
+	if rp.valid == 0 {
+		res.PerRule, _ = rp.ruleResults()
+		msg := "no valid rules"
+		res.Error = &msg
+		return res, errors.New(msg)
+	}
+
+	// rows are copied by sliceRows, so the input table is never modified
+	out := newRowCollector()
+	processed, err := replaceRows(rp, &sliceRows{rows: req.Dataset.Rows}, out)
+	if err != nil {
+		msg := err.Error()
+		res.Error = &msg
+		return res, err
+	}
+	perRuleRes, totalReplacements := rp.ruleResults()
 
 	// assemble response
 	res.Result = types.TableData{
 		HasHeader: req.Dataset.HasHeader,
 		Header:    append([]string(nil), req.Dataset.Header...),
-		Rows:      outRows,
+		Rows:      out.rows,
 	}
 	res.PerRule = perRuleRes
+	if rp.log != nil {
+		res.Changes = rp.log.changes
+	}
 	res.Summary = types.ResultSummary{
-		Processed:  len(req.Dataset.Rows),
+		Processed:  processed,
 		Matched:    totalReplacements, // number of replacements occurrences
 		Missing:    0,
 		DurationMS: time.Since(start).Milliseconds(),
@@ -275,3 +555,75 @@ func FindAndReplace(req FindReplaceRequest) (FindReplaceResponse, error) {
 	res.Error = nil
 	return res, nil
 }
+
+// FindAndReplaceStream is FindAndReplace from src to dst (see StreamTable). A dry run
+// writes the header and changed rows only with changed_rows.
+func FindAndReplaceStream(req FindReplaceRequest, src types.RowStream, dst types.RowWriter) (FindReplaceResponse, error) {
+	var res FindReplaceResponse
+	res.Operation = req.Operation
+	start := time.Now()
+
+	if src.Rows == nil {
+		msg := "dataset required"
+		res.Error = &msg
+		return res, errors.New(msg)
+	}
+	issues, err := expandMapping(&req)
+	res.Mapping = issues
+	if err != nil {
+		msg := err.Error()
+		res.Error = &msg
+		return res, err
+	}
+	if len(req.Rules) == 0 {
+		msg := "no rules provided"
+		res.Error = &msg
+		return res, errors.New(msg)
+	}
+
+	fail := func(err error) (FindReplaceResponse, error) {
+		msg := err.Error()
+		res.Error = &msg
+		return res, err
+	}
+
+	shape, rows, err := peekFirst(src)
+	if err != nil {
+		return fail(err)
+	}
+	rp, err := newReplacer(req, shape)
+	if err != nil {
+		return fail(err)
+	}
+	if rp.valid == 0 {
+		res.PerRule, _ = rp.ruleResults()
+		return fail(errors.New("no valid rules"))
+	}
+	// a dry run without changed_rows writes nothing, not even the header
+	if rp.log.write(true) {
+		if err := writeHeader(src, dst); err != nil {
+			return fail(err)
+		}
+	}
+	processed, err := replaceRows(rp, rows, dst)
+	if err != nil {
+		return fail(err)
+	}
+	perRuleRes, totalReplacements := rp.ruleResults()
+
+	res.PerRule = perRuleRes
+	if rp.log != nil {
+		res.Changes = rp.log.changes
+	}
+	res.Summary = types.ResultSummary{
+		Processed:  processed,
+		Matched:    totalReplacements,
+		Missing:    0,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	res.Result = types.TableData{
+		HasHeader: src.HasHeader,
+		Header:    append([]string(nil), src.Header...),
+	}
+	return res, nil
+}
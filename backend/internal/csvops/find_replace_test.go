@@ -0,0 +1,149 @@
+package csvops
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+)
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestApplyRowRestoresTrimmedWhitespace(t *testing.T) {
+	tests := []struct {
+		name    string
+		cell    string
+		rule    ReplaceRule
+		opts    FindReplaceOptions
+		want    string
+		changed bool
+	}{
+		{"whole cell keeps padding", "  usa \t", ReplaceRule{Targets: []string{"usa"}, Replacement: "USA", WholeCell: boolPtr(true)}, FindReplaceOptions{TrimSpaces: true}, "  USA \t", true},
+		{"whole cell needs trim to match", "  usa ", ReplaceRule{Targets: []string{"usa"}, Replacement: "USA", WholeCell: boolPtr(true)}, FindReplaceOptions{}, "  usa ", false},
+		{"substring keeps offsets", " a-b ", ReplaceRule{Targets: []string{"-"}, Replacement: "--"}, FindReplaceOptions{TrimSpaces: true}, " a--b ", true},
+		{"anchored regex", "  v1 ", ReplaceRule{Targets: []string{`^v(\d)$`}, Replacement: "version $1", Mode: ReplaceRegex}, FindReplaceOptions{TrimSpaces: true}, "  version 1 ", true},
+		{"no match leaves cell alone", "  x  ", ReplaceRule{Targets: []string{"y"}, Replacement: "z"}, FindReplaceOptions{TrimSpaces: true}, "  x  ", false},
+		{"trim output drops padding", "  usa ", ReplaceRule{Targets: []string{"usa"}, Replacement: "USA", WholeCell: boolPtr(true)}, FindReplaceOptions{TrimSpaces: true, TrimOutput: true}, "USA", true},
+		{"trim output of unmatched cell", "  x ", ReplaceRule{Targets: []string{"y"}, Replacement: "z"}, FindReplaceOptions{TrimOutput: true}, "x", true},
+		{"inner whitespace untouched", " New  York ", ReplaceRule{Targets: []string{"York"}, Replacement: "Jersey"}, FindReplaceOptions{TrimSpaces: true}, " New  Jersey ", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := FindReplaceRequest{Options: tt.opts, Rules: []ReplaceRule{tt.rule}}
+			rp, err := newReplacer(req, types.TableData{Rows: [][]string{{tt.cell}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rp.valid != 1 {
+				t.Fatalf("rule did not compile: %v", rp.compiled[0].err)
+			}
+			row, changed, err := rp.applyRow([]string{tt.cell})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if row[0] != tt.want || changed != tt.changed {
+				t.Errorf("applyRow(%q) = %q, %v, want %q, %v", tt.cell, row[0], changed, tt.want, tt.changed)
+			}
+		})
+	}
+}
+
+func TestFindAndReplaceWhere(t *testing.T) {
+	dataset := types.TableData{
+		HasHeader: true,
+		Header:    []string{"Region", "Status", "Score"},
+		Rows: [][]string{
+			{"EMEA", "Pending", "5"},
+			{"APAC", "Pending", "7"},
+			{"EMEA", "Open", "9"},
+			{"AMER", "Pending", "2"},
+		},
+	}
+	pendingToClosed := ReplaceRule{Targets: []string{"Pending"}, Replacement: "Closed", WholeCell: boolPtr(true)}
+	emea := ConditionGroup{Op: "and", Conds: []Condition{{Column: "Region", Operator: OpEquals, Value: "emea"}}}
+	emeaOrAPAC := ConditionGroup{Op: "or", Conds: []Condition{emea.Conds[0], {Column: "Region", Operator: OpEquals, Value: "APAC"}}}
+	whereCI := FindReplaceOptions{WhereOptions: AdvancedExtractOptions{CaseInsensitive: true}}
+
+	tests := []struct {
+		name   string
+		rules  []ReplaceRule
+		where  *ConditionGroup
+		opts   FindReplaceOptions
+		status []string
+	}{
+		{"no filter", []ReplaceRule{pendingToClosed}, nil, FindReplaceOptions{}, []string{"Closed", "Closed", "Open", "Closed"}},
+		{"request filter", []ReplaceRule{pendingToClosed}, &emea, whereCI, []string{"Closed", "Pending", "Open", "Pending"}},
+		{"request filter is case-sensitive by default", []ReplaceRule{pendingToClosed}, &emea, FindReplaceOptions{}, []string{"Pending", "Pending", "Open", "Pending"}},
+		// the find options do not reach the filter: "pending" finds "Pending", but
+		// "emea" does not select the EMEA rows
+		{
+			"case-insensitive find, case-sensitive filter",
+			[]ReplaceRule{{Targets: []string{"pending"}, Replacement: "Closed", WholeCell: boolPtr(true)}},
+			&emeaOrAPAC,
+			FindReplaceOptions{CaseInsensitive: true},
+			[]string{"Pending", "Closed", "Open", "Pending"},
+		},
+		{
+			"case-insensitive find and filter",
+			[]ReplaceRule{{Targets: []string{"pending"}, Replacement: "Closed", WholeCell: boolPtr(true)}},
+			&emeaOrAPAC,
+			FindReplaceOptions{CaseInsensitive: true, WhereOptions: AdvancedExtractOptions{CaseInsensitive: true}},
+			[]string{"Closed", "Closed", "Open", "Pending"},
+		},
+		{
+			"or with a subgroup",
+			[]ReplaceRule{pendingToClosed},
+			&ConditionGroup{Op: "or", Conds: []Condition{{Column: "Region", Operator: OpEquals, Value: "AMER"}}, SubGroups: []ConditionGroup{
+				{Op: "and", Conds: []Condition{{Column: "Region", Operator: OpEquals, Value: "APAC"}, {Column: "Score", Operator: OpGt, Value: 6}}},
+			}},
+			FindReplaceOptions{},
+			[]string{"Pending", "Closed", "Open", "Closed"},
+		},
+		{
+			"rule filters",
+			[]ReplaceRule{
+				{Targets: []string{"Pending"}, Replacement: "Closed", WholeCell: boolPtr(true), Where: &ConditionGroup{Op: "and", Conds: []Condition{{Column: "Score", Operator: OpLt, Value: 6}}}},
+				{Targets: []string{"Pending"}, Replacement: "Escalated", WholeCell: boolPtr(true), Where: &ConditionGroup{Op: "and", Conds: []Condition{{Column: "Score", Operator: OpGte, Value: 6}}}},
+			},
+			nil,
+			FindReplaceOptions{},
+			[]string{"Closed", "Escalated", "Open", "Closed"},
+		},
+		{
+			// conditions see the row as read, not the cells earlier rules rewrote
+			"filters see the original row",
+			[]ReplaceRule{
+				{Targets: []string{"Pending"}, Replacement: "Closed", WholeCell: boolPtr(true)},
+				{Targets: []string{"Closed"}, Replacement: "Archived", WholeCell: boolPtr(true), Where: &ConditionGroup{Op: "and", Conds: []Condition{{Column: "Status", Operator: OpEquals, Value: "Closed"}}}},
+			},
+			nil,
+			FindReplaceOptions{},
+			[]string{"Closed", "Closed", "Open", "Closed"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.Columns = []string{"Status"}
+			res, err := FindAndReplace(FindReplaceRequest{
+				Options: opts,
+				Dataset: dataset,
+				Rules:   tt.rules,
+				Where:   tt.where,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var status []string
+			for _, r := range res.Result.Rows {
+				status = append(status, r[1])
+			}
+			if !reflect.DeepEqual(status, tt.status) {
+				t.Errorf("Status = %v, want %v", status, tt.status)
+			}
+			if dataset.Rows[0][1] != "Pending" {
+				t.Fatal("FindAndReplace modified the input dataset")
+			}
+		})
+	}
+}
@@ -0,0 +1,454 @@
+package csvops
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/utils"
+)
+
+// Fuzzy matching: list keys are compared with master keys by similarity instead of
+// equality. All fuzzy methods compare trimmed, case-folded text; the parts of a
+// composite key are compared as words of one string.
+
+const (
+	MatchLevenshtein MatchMethod = "levenshtein"  // 1 - edit distance / longer length
+	MatchDamerau     MatchMethod = "damerau"      // like levenshtein, transpositions cost 1
+	MatchJaroWinkler MatchMethod = "jaro_winkler" // favours common prefixes; good for short names
+	MatchTokenSet    MatchMethod = "token_set"    // word-order and extra-word tolerant ("Acme Corp." ~ "ACME Corporation")
+	MatchSoundex     MatchMethod = "soundex"      // same Soundex code per word
+	MatchMetaphone   MatchMethod = "metaphone"    // same Double Metaphone code (primary or alternate) per word
+)
+
+// DefaultFuzzyThreshold is used by the similarity methods when no threshold is given.
+// Phonetic methods accept every candidate with the same code unless a threshold is set.
+const DefaultFuzzyThreshold = 0.85
+
+const (
+	// fuzzyCandidates is how many master keys sharing the most n-grams with a list
+	// key are scored exactly.
+	fuzzyCandidates = 64
+	// minPostingGrams is how many of a key's rarest n-grams are always looked up,
+	// even when they are common; rarer-than-maxPosting grams are used in addition.
+	minPostingGrams = 3
+)
+
+// FuzzyMatch is the best master candidate found for one list row by a fuzzy method.
+type FuzzyMatch struct {
+	Row       int     `json:"row"`       // 1-based data row number in the list
+	Value     string  `json:"value"`     // list key value
+	Candidate string  `json:"candidate"` // best master key value ("" when there is none)
+	Score     float64 `json:"score"`     // similarity in [0, 1]
+	Matched   bool    `json:"matched"`   // score reached the threshold
+}
+
+// MatchScoresHeader is the header of the rows FuzzyMatch.Record returns.
+var MatchScoresHeader = []string{"row", "value", "candidate", "score", "matched"}
+
+// Record returns m as a row under MatchScoresHeader, with the score to 4 decimals.
+func (m FuzzyMatch) Record() []string {
+	return []string{
+		strconv.Itoa(m.Row), m.Value, m.Candidate,
+		strconv.FormatFloat(m.Score, 'f', 4, 64), strconv.FormatBool(m.Matched),
+	}
+}
+
+// IsFuzzy reports whether m compares by similarity rather than equality.
+func (m MatchMethod) IsFuzzy() bool {
+	switch m {
+	case MatchLevenshtein, MatchDamerau, MatchJaroWinkler, MatchTokenSet, MatchSoundex, MatchMetaphone:
+		return true
+	}
+	return false
+}
+
+func (m MatchMethod) isPhonetic() bool {
+	return m == MatchSoundex || m == MatchMetaphone
+}
+
+// fuzzyText turns a normalized (possibly composite) key into the text fuzzy methods compare.
+func fuzzyText(key string) string {
+	return strings.ReplaceAll(key, utils.KeySeparator, " ")
+}
+
+// fuzzyIndex finds the most similar master key for a list key. Similarity methods
+// only score the master keys that share the most (rarest) character trigrams with
+// the list key; phonetic methods only score master keys with the same code. Both
+// keep lookups far below a full scan on large masters.
+type fuzzyIndex struct {
+	method    MatchMethod
+	threshold float64
+	keys      [][]rune // distinct master keys, in first-seen order
+	display   []string // master key as it appears in the first row that has it
+	exact     map[string]int
+
+	grams      map[string][]int32 // trigram -> keys containing it (similarity methods)
+	maxPosting int
+	counts     []uint16 // per-key shared trigram counts, reset after each lookup
+	touched    []int32
+
+	codes map[string][]int32 // phonetic code -> keys (phonetic methods)
+}
+
+func newFuzzyIndex(method MatchMethod, threshold float64) *fuzzyIndex {
+	if threshold <= 0 && !method.isPhonetic() {
+		threshold = DefaultFuzzyThreshold
+	}
+	ix := &fuzzyIndex{method: method, threshold: threshold, exact: map[string]int{}}
+	if method.isPhonetic() {
+		ix.codes = map[string][]int32{}
+	} else {
+		ix.grams = map[string][]int32{}
+	}
+	return ix
+}
+
+// add indexes one master key; repeated keys keep their first display value.
+func (ix *fuzzyIndex) add(key, display string) {
+	text := fuzzyText(key)
+	if _, dup := ix.exact[text]; dup {
+		return
+	}
+	id := int32(len(ix.keys))
+	ix.exact[text] = int(id)
+	ix.keys = append(ix.keys, []rune(text))
+	ix.display = append(ix.display, display)
+	if ix.codes != nil {
+		for _, code := range phoneticCodes(ix.method, text) {
+			ix.codes[code] = append(ix.codes[code], id)
+		}
+		return
+	}
+	for _, g := range trigrams(text) {
+		ix.grams[g] = append(ix.grams[g], id)
+	}
+}
+
+// finish prepares the index for lookups once every master key is added.
+func (ix *fuzzyIndex) finish() {
+	ix.counts = make([]uint16, len(ix.keys))
+	// grams in more than 2% of the keys say little about similarity
+	ix.maxPosting = len(ix.keys) / 50
+	if ix.maxPosting < 1000 {
+		ix.maxPosting = 1000
+	}
+}
+
+// lookup returns the best master key for a normalized list key: its index (-1 when
+// there is no candidate) and its similarity.
+func (ix *fuzzyIndex) lookup(key string) (int, float64) {
+	text := fuzzyText(key)
+	if id, ok := ix.exact[text]; ok {
+		return id, 1
+	}
+	q := []rune(text)
+	best, bestScore := -1, -1.0
+	for _, id := range ix.candidates(text) {
+		if s := similarity(ix.method, q, ix.keys[id]); s > bestScore || (s == bestScore && int(id) < best) {
+			best, bestScore = int(id), s
+		}
+	}
+	if best < 0 {
+		return -1, 0
+	}
+	return best, bestScore
+}
+
+// match looks up the normalized list key of data row rowNum and returns the
+// FuzzyMatch recorded for it, with the index of the accepted master key (-1 when
+// the best candidate is below the threshold or there is none).
+func (ix *fuzzyIndex) match(rowNum int, key, display string) (FuzzyMatch, int) {
+	id, score := ix.lookup(key)
+	fm := FuzzyMatch{
+		Row:     rowNum,
+		Value:   display,
+		Score:   math.Round(score*1e4) / 1e4,
+		Matched: id >= 0 && score >= ix.threshold,
+	}
+	if id >= 0 {
+		fm.Candidate = ix.display[id]
+	}
+	if !fm.Matched {
+		return fm, -1
+	}
+	return fm, id
+}
+
+// candidates returns the master keys worth scoring for text.
+func (ix *fuzzyIndex) candidates(text string) []int32 {
+	if ix.codes != nil {
+		var out []int32
+		seen := map[int32]bool{}
+		for _, code := range phoneticCodes(ix.method, text) {
+			for _, id := range ix.codes[code] {
+				if !seen[id] {
+					seen[id] = true
+					out = append(out, id)
+				}
+			}
+		}
+		return out
+	}
+
+	grams := trigrams(text)
+	sort.Slice(grams, func(i, j int) bool { return len(ix.grams[grams[i]]) < len(ix.grams[grams[j]]) })
+	for i, g := range grams {
+		posting := ix.grams[g]
+		if i >= minPostingGrams && len(posting) > ix.maxPosting {
+			break
+		}
+		for _, id := range posting {
+			if ix.counts[id] == 0 {
+				ix.touched = append(ix.touched, id)
+			}
+			ix.counts[id]++
+		}
+	}
+	out := ix.topCandidates()
+	for _, id := range ix.touched {
+		ix.counts[id] = 0
+	}
+	ix.touched = ix.touched[:0]
+	return out
+}
+
+// topCandidates returns the (at most fuzzyCandidates) touched keys with the highest
+// shared trigram counts. A count histogram finds the cutoff count; keys at the
+// cutoff fill the remaining slots in the order they were found.
+func (ix *fuzzyIndex) topCandidates() []int32 {
+	var hist []int
+	for _, id := range ix.touched {
+		c := int(ix.counts[id])
+		for len(hist) <= c {
+			hist = append(hist, 0)
+		}
+		hist[c]++
+	}
+	cutoff, above := 0, 0
+	for c := len(hist) - 1; c > 0; c-- {
+		if above+hist[c] >= fuzzyCandidates {
+			cutoff = c
+			break
+		}
+		above += hist[c]
+	}
+	out := make([]int32, 0, fuzzyCandidates)
+	atCutoff := fuzzyCandidates - above
+	for _, id := range ix.touched {
+		switch c := int(ix.counts[id]); {
+		case c > cutoff:
+			out = append(out, id)
+		case c == cutoff && atCutoff > 0:
+			out = append(out, id)
+			atCutoff--
+		}
+	}
+	return out
+}
+
+// trigrams returns the distinct rune trigrams of s padded with spaces, so short
+// keys and word boundaries produce grams too.
+func trigrams(s string) []string {
+	r := []rune("  " + s + " ")
+	seen := make(map[string]bool, len(r))
+	out := make([]string, 0, len(r))
+	for i := 0; i+3 <= len(r); i++ {
+		g := string(r[i : i+3])
+		if !seen[g] {
+			seen[g] = true
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// similarity scores a and b in [0, 1] with the given method. Phonetic methods rank
+// their same-code candidates by Jaro-Winkler.
+func similarity(method MatchMethod, a, b []rune) float64 {
+	switch method {
+	case MatchLevenshtein:
+		return distanceRatio(levenshtein(a, b), len(a), len(b))
+	case MatchDamerau:
+		return distanceRatio(damerauOSA(a, b), len(a), len(b))
+	case MatchTokenSet:
+		return tokenSetRatio(string(a), string(b))
+	}
+	return jaroWinkler(a, b)
+}
+
+// distanceRatio turns an edit distance into a similarity relative to the longer string.
+func distanceRatio(d, la, lb int) float64 {
+	n := la
+	if lb > n {
+		n = lb
+	}
+	if n == 0 {
+		return 1
+	}
+	return 1 - float64(d)/float64(n)
+}
+
+// levenshtein is the insert/delete/substitute edit distance.
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// damerauOSA is the optimal string alignment distance: levenshtein plus adjacent
+// transpositions ("Jhon" -> "John" costs 1).
+func damerauOSA(a, b []rune) int {
+	rows := [3][]int{make([]int, len(b)+1), make([]int, len(b)+1), make([]int, len(b)+1)}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		pp, prev, cur := rows[(i+1)%3], rows[(i+2)%3], rows[i%3]
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], pp[j-2]+1)
+			}
+		}
+	}
+	return rows[len(a)%3][len(b)]
+}
+
+// jaroWinkler is the Jaro similarity boosted by up to four common prefix runes.
+func jaroWinkler(a, b []rune) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	window := max(len(a), len(b))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+	aMatch := make([]bool, len(a))
+	bMatch := make([]bool, len(b))
+	matches := 0
+	for i := range a {
+		lo, hi := max(0, i-window), min(len(b), i+window+1)
+		for j := lo; j < hi; j++ {
+			if !bMatch[j] && a[i] == b[j] {
+				aMatch[i], bMatch[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+	transpositions, j := 0, 0
+	for i := range a {
+		if !aMatch[i] {
+			continue
+		}
+		for !bMatch[j] {
+			j++
+		}
+		if a[i] != b[j] {
+			transpositions++
+		}
+		j++
+	}
+	m := float64(matches)
+	jaro := (m/float64(len(a)) + m/float64(len(b)) + (m-float64(transpositions)/2)/m) / 3
+	if jaro <= 0.7 {
+		return jaro
+	}
+	prefix := 0
+	for prefix < 4 && prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// indelRatio is 2*LCS/(len(a)+len(b)): the similarity used by token set matching.
+func indelRatio(a, b []rune) float64 {
+	if len(a)+len(b) == 0 {
+		return 1
+	}
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				cur[j] = prev[j-1] + 1
+			} else {
+				cur[j] = max(prev[j], cur[j-1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return 2 * float64(prev[len(b)]) / float64(len(a)+len(b))
+}
+
+// words splits s into its letter/digit runs, dropping punctuation.
+func words(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+}
+
+// tokenSetRatio compares the shared words of a and b with each side's full word
+// set, so extra words and word order matter little.
+func tokenSetRatio(a, b string) float64 {
+	ta, tb := wordSet(a), wordSet(b)
+	var inter, onlyA, onlyB []string
+	for w := range ta {
+		if tb[w] {
+			inter = append(inter, w)
+		} else {
+			onlyA = append(onlyA, w)
+		}
+	}
+	for w := range tb {
+		if !ta[w] {
+			onlyB = append(onlyB, w)
+		}
+	}
+	sort.Strings(inter)
+	sort.Strings(onlyA)
+	sort.Strings(onlyB)
+	sect := strings.Join(inter, " ")
+	withA := strings.TrimSpace(sect + " " + strings.Join(onlyA, " "))
+	withB := strings.TrimSpace(sect + " " + strings.Join(onlyB, " "))
+
+	best := indelRatio([]rune(withA), []rune(withB))
+	if sect != "" {
+		best = max(best, indelRatio([]rune(sect), []rune(withA)), indelRatio([]rune(sect), []rune(withB)))
+	}
+	return best
+}
+
+func wordSet(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range words(s) {
+		set[w] = true
+	}
+	return set
+}
@@ -0,0 +1,183 @@
+package csvops
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+)
+
+func TestSoundex(t *testing.T) {
+	tests := []struct{ word, want string }{
+		{"ROBERT", "R163"},
+		{"RUPERT", "R163"},
+		{"RUBIN", "R150"},
+		{"ASHCRAFT", "A261"}, // H does not separate S and C
+		{"TYMCZAK", "T522"},
+		{"PFISTER", "P236"}, // F has the first letter's code
+		{"HONEYMAN", "H555"},
+		{"LEE", "L000"},
+		{"O'BRIEN", "O165"},
+		{"123", ""},
+	}
+	for _, tt := range tests {
+		if got := soundex(tt.word); got != tt.want {
+			t.Errorf("soundex(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestDoubleMetaphone(t *testing.T) {
+	tests := []struct{ word, primary, alternate string }{
+		{"SMITH", "SM0", "XMT"},
+		{"SCHMIDT", "XMT", "SMT"},
+		{"THOMAS", "TMS", "TMS"},
+		{"CATHERINE", "K0RN", "KTRN"},
+		{"KNIGHT", "NT", "NT"},
+		{"PHILIP", "FLP", "FLP"},
+		{"GEORGE", "JRJ", "KRK"},
+		{"JOSE", "HS", "HS"},
+		{"JOHN", "JN", "AN"},
+		{"DUMB", "TM", "TM"},
+		{"XAVIER", "SF", "SFR"},
+		{"CZERNY", "SRN", "XRN"},
+		{"ARNOW", "ARN", "ARNF"},
+	}
+	for _, tt := range tests {
+		p, a := doubleMetaphone(tt.word)
+		if p != tt.primary || a != tt.alternate {
+			t.Errorf("doubleMetaphone(%q) = %q, %q, want %q, %q", tt.word, p, a, tt.primary, tt.alternate)
+		}
+	}
+}
+
+func TestFuzzyCandidatesRankBySharedTrigrams(t *testing.T) {
+	ix := newFuzzyIndex(MatchLevenshtein, 0)
+	for i := 0; i < 200; i++ {
+		k := fmt.Sprintf("item%03d", i)
+		ix.add(k, k)
+	}
+	ix.finish()
+
+	got := map[string]bool{}
+	for _, id := range ix.candidates("item042") {
+		got[string(ix.keys[id])] = true
+	}
+	if len(got) != fuzzyCandidates {
+		t.Fatalf("got %d candidates, want %d", len(got), fuzzyCandidates)
+	}
+	// item04x share "m04" on top of the grams every item0xx key shares
+	for i := 40; i < 50; i++ {
+		if k := fmt.Sprintf("item%03d", i); !got[k] {
+			t.Errorf("candidates miss %s", k)
+		}
+	}
+	// no key left out shares more trigrams than a candidate
+	minIn, maxOut := 1<<30, 0
+	for _, k := range ix.keys {
+		n := sharedTrigrams("item042", string(k))
+		if got[string(k)] {
+			minIn = min(minIn, n)
+		} else {
+			maxOut = max(maxOut, n)
+		}
+	}
+	if maxOut > minIn {
+		t.Errorf("a key sharing %d trigrams was left out for one sharing %d", maxOut, minIn)
+	}
+	// the counts are reset for the next lookup
+	if len(ix.candidates("item142")) != fuzzyCandidates || len(ix.touched) != 0 {
+		t.Errorf("lookup state not reset")
+	}
+}
+
+func TestFuzzyLookup(t *testing.T) {
+	master := []string{"acme corporation", "globex inc", "initech", "umbrella corp"}
+	tests := []struct {
+		method MatchMethod
+		key    string
+		want   string
+		score  float64 // minimum expected similarity
+	}{
+		{MatchLevenshtein, "initech", "initech", 1},
+		{MatchLevenshtein, "inittech", "initech", 0.85},
+		{MatchDamerau, "intiech", "initech", 0.85},
+		{MatchJaroWinkler, "globex", "globex inc", 0.9},
+		{MatchTokenSet, "corporation acme", "acme corporation", 1},
+		{MatchSoundex, "globecks inc", "globex inc", 0},
+		{MatchMetaphone, "umbrela korp", "umbrella corp", 0},
+		{MatchLevenshtein, "zzzz", "", 0},
+	}
+	for _, tt := range tests {
+		ix := newFuzzyIndex(tt.method, 0)
+		for _, k := range master {
+			ix.add(k, k)
+		}
+		ix.finish()
+		id, score := ix.lookup(tt.key)
+		got := ""
+		if id >= 0 {
+			got = string(ix.keys[id])
+		}
+		if got != tt.want || score < tt.score {
+			t.Errorf("%s lookup(%q) = %q (%.3f), want %q (>= %.2f)", tt.method, tt.key, got, score, tt.want, tt.score)
+		}
+	}
+}
+
+// Streamed crossref writes fuzzy scores to StreamTable.Scores instead of keeping
+// them in PerListResult.Matches.
+func TestCrossRefStreamWritesFuzzyScores(t *testing.T) {
+	list := types.TableData{HasHeader: true, Header: []string{"name"}, Rows: [][]string{{"inittech"}, {"zzzz"}}}
+	req := CrossRefMultiRequest{
+		Options: CrossRefMultiOptions{MasterKey: "name", MatchMethod: MatchLevenshtein},
+		Datasets: types.MultiDatasets{
+			Master: types.TableData{HasHeader: true, Header: []string{"name"}, Rows: [][]string{{"initech"}, {"globex"}}},
+			Lists:  []types.NamedTable{{Name: "list", Table: list}},
+		},
+	}
+	mem, err := CrossRefMulti(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{MatchScoresHeader}
+	for _, m := range mem.PerList[0].Matches {
+		want = append(want, m.Record())
+	}
+	if len(want) != 3 {
+		t.Fatalf("in-memory matches = %v, want one per list row", mem.PerList[0].Matches)
+	}
+
+	scores := newRowCollector()
+	res, err := CrossRefMultiStream(req, []StreamTable{{
+		Name:   "list",
+		Input:  types.RowStream{HasHeader: true, Header: list.Header, Rows: &sliceRows{rows: list.Rows}},
+		Output: newRowCollector(),
+		Scores: scores,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pl := res.PerList[0]
+	if pl.Error != nil || pl.Matched != 1 || len(pl.Matches) != 0 {
+		t.Errorf("stream result: error %v, matched %d, %d matches kept", pl.Error, pl.Matched, len(pl.Matches))
+	}
+	if !reflect.DeepEqual(scores.rows, want) {
+		t.Errorf("scores = %v, want %v", scores.rows, want)
+	}
+}
+
+func sharedTrigrams(a, b string) int {
+	in := map[string]bool{}
+	for _, g := range trigrams(b) {
+		in[g] = true
+	}
+	n := 0
+	for _, g := range trigrams(a) {
+		if in[g] {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,356 @@
+package csvops
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/utils"
+)
+
+// Join enriches each list with the master: list rows are matched on the key the
+// way CrossRefMulti matches them, and the selected master columns of the matching
+// master row are appended to every list row.
+
+type JoinType string
+
+const (
+	JoinInner JoinType = "inner" // matched list rows only
+	JoinLeft  JoinType = "left"  // every list row; unmatched rows get empty master columns (default)
+	JoinRight JoinType = "right" // matched list rows plus the master rows no list row matched
+	JoinFull  JoinType = "full"  // every list row plus the master rows no list row matched
+)
+
+// DuplicatePolicy decides which master rows a list row is joined with when
+// several master rows share its key.
+type DuplicatePolicy string
+
+const (
+	DuplicatesFirst DuplicatePolicy = "first" // the first master row with the key (default)
+	DuplicatesLast  DuplicatePolicy = "last"  // the last master row with the key
+	DuplicatesAll   DuplicatePolicy = "all"   // one result row per master row with the key
+	DuplicatesError DuplicatePolicy = "error" // fail the list
+)
+
+// DefaultJoinSuffix is appended to master column names that clash with list columns.
+const DefaultJoinSuffix = "_master"
+
+// JoinRequest has the CrossRefMultiRequest shape: a master and named lists.
+type JoinRequest struct {
+	Operation string              `json:"operation"`
+	Options   JoinOptions         `json:"options"`
+	Datasets  types.MultiDatasets `json:"datasets"`
+}
+
+// JoinOptions extends the crossref options (match_method, master_key(s), list_key(s),
+// trim_spaces, threshold) with the join shape.
+type JoinOptions struct {
+	CrossRefMultiOptions
+	Type       JoinType        `json:"join_type"`            // inner | left | right | full; "" => left
+	Columns    []string        `json:"columns,omitempty"`    // master columns to append; empty => all but the key columns
+	Suffix     string          `json:"suffix,omitempty"`     // for clashing header names; "" => "_master"
+	Duplicates DuplicatePolicy `json:"duplicates,omitempty"` // first | last | all | error; "" => first
+}
+
+// PerJoinResult holds the counts and joined rows for one list.
+type PerJoinResult struct {
+	Name       string          `json:"name"`
+	Processed  int             `json:"processed"`   // list rows read
+	Matched    int             `json:"matched"`     // list rows that found a master row
+	Missing    int             `json:"missing"`     // list rows that found none
+	Duplicated int             `json:"duplicated"`  // matched list rows whose key is on several master rows
+	MasterOnly int             `json:"master_only"` // master rows no list row matched
+	Result     types.TableData `json:"result"`      // list columns followed by the master columns
+	Matches    []FuzzyMatch    `json:"matches,omitempty"`
+	Error      *string         `json:"error"`
+}
+
+type JoinResponse struct {
+	Operation string          `json:"operation"`
+	Summary   map[string]int  `json:"summary"`
+	PerList   []PerJoinResult `json:"per_list"`
+	Error     *string         `json:"error"`
+}
+
+// validateJoinOptions checks the match, join type and duplicate policy options.
+func validateJoinOptions(o JoinOptions) error {
+	if err := validateMatchOptions(o.CrossRefMultiOptions); err != nil {
+		return err
+	}
+	if len(masterKeysFor(o.CrossRefMultiOptions)) == 0 {
+		return errors.New("master_key required")
+	}
+	switch o.Type {
+	case "", JoinInner, JoinLeft, JoinRight, JoinFull:
+	default:
+		return fmt.Errorf("invalid join type '%s' (expected inner | left | right | full)", o.Type)
+	}
+	switch o.Duplicates {
+	case "", DuplicatesFirst, DuplicatesLast, DuplicatesAll, DuplicatesError:
+	default:
+		return fmt.Errorf("invalid duplicates policy '%s' (expected first | last | all | error)", o.Duplicates)
+	}
+	return nil
+}
+
+// joinIndex groups the master rows by normalized key. For fuzzy methods the groups
+// are keyed like fuzzyIndex keys, so a fuzzyIndex id is also a group id.
+type joinIndex struct {
+	normalize func(string) string
+	groups    map[string]int
+	rows      [][]int // group -> master row indices, in input order
+	rowGroup  []int   // master row -> group (-1 when the row has no key)
+	fuzzy     *fuzzyIndex
+}
+
+func buildJoinIndex(opts CrossRefMultiOptions, master types.TableData, mKeyIdxs []int) (*joinIndex, error) {
+	normalize, err := opts.keyNormalizer()
+	if err != nil {
+		return nil, err
+	}
+	ix := &joinIndex{normalize: normalize, groups: map[string]int{}, rowGroup: make([]int, len(master.Rows))}
+	if opts.MatchMethod.IsFuzzy() {
+		ix.fuzzy = newFuzzyIndex(opts.MatchMethod, opts.Threshold)
+	}
+	for i, row := range master.Rows {
+		ix.rowGroup[i] = -1
+		if !hasKeyCells(row, mKeyIdxs) {
+			continue
+		}
+		n := utils.CompositeKey(row, mKeyIdxs, normalize)
+		k := n
+		if ix.fuzzy != nil {
+			k = fuzzyText(n)
+			ix.fuzzy.add(n, keyDisplay(row, mKeyIdxs))
+		}
+		g, ok := ix.groups[k]
+		if !ok {
+			g = len(ix.rows)
+			ix.groups[k] = g
+			ix.rows = append(ix.rows, nil)
+		}
+		ix.rows[g] = append(ix.rows[g], i)
+		ix.rowGroup[i] = g
+	}
+	if ix.fuzzy != nil {
+		ix.fuzzy.finish()
+	}
+	return ix, nil
+}
+
+// resolveJoinColumns resolves the master columns to append. Without explicit
+// columns every master column except the key columns is used.
+func resolveJoinColumns(master types.TableData, columns []string, mKeyIdxs []int) ([]int, error) {
+	if len(columns) > 0 {
+		return utils.ResolveKeyIndices(master, columns)
+	}
+	isKey := map[int]bool{}
+	for _, k := range mKeyIdxs {
+		isKey[k] = true
+	}
+	var cols []int
+	for i := 0; i < tableWidth(master); i++ {
+		if !isKey[i] {
+			cols = append(cols, i)
+		}
+	}
+	return cols, nil
+}
+
+// tableWidth returns the number of columns of t: its header or its widest row.
+func tableWidth(t types.TableData) int {
+	w := len(t.Header)
+	for _, r := range t.Rows {
+		if len(r) > w {
+			w = len(r)
+		}
+	}
+	return w
+}
+
+// joinHeader returns the list header followed by the names of the master columns,
+// suffixed while they clash with a name already in the header. A master without a
+// header names its columns master_<index>.
+func joinHeader(list, master types.TableData, width int, cols []int, suffix string) []string {
+	header := make([]string, width, width+len(cols))
+	copy(header, list.Header)
+	taken := make(map[string]bool, width+len(cols))
+	for _, h := range header {
+		taken[strings.ToLower(strings.TrimSpace(h))] = true
+	}
+	for _, c := range cols {
+		name := "master_" + strconv.Itoa(c)
+		if master.HasHeader && c < len(master.Header) {
+			name = master.Header[c]
+		}
+		for base, n := name, 1; taken[strings.ToLower(strings.TrimSpace(name))]; n++ {
+			name = base + suffix
+			if n > 1 {
+				name += strconv.Itoa(n)
+			}
+		}
+		taken[strings.ToLower(strings.TrimSpace(name))] = true
+		header = append(header, name)
+	}
+	return header
+}
+
+// joinRow returns the list row padded to width followed by the master columns
+// cols of mrow (empty when mrow is nil).
+func joinRow(row []string, width int, mrow []string, cols []int) []string {
+	out := make([]string, width, width+len(cols))
+	copy(out, row)
+	for _, c := range cols {
+		if mrow == nil {
+			out = append(out, "")
+		} else {
+			out = append(out, cellAt(mrow, c))
+		}
+	}
+	return out
+}
+
+// Join appends the selected master columns to the rows of every list. Non-fatal
+// list-level errors are reported in per_list[].error.
+func Join(req JoinRequest) (JoinResponse, error) {
+	var res JoinResponse
+	res.Operation = req.Operation
+	start := time.Now()
+
+	opts := req.Options
+	if err := validateJoinOptions(opts); err != nil {
+		msg := err.Error()
+		res.Error = &msg
+		return res, err
+	}
+	master := req.Datasets.Master
+	mKeyIdxs, err := utils.ResolveKeyIndices(master, masterKeysFor(opts.CrossRefMultiOptions))
+	if err != nil {
+		msg := "master key resolution: " + err.Error()
+		res.Error = &msg
+		return res, errors.New(msg)
+	}
+	cols, err := resolveJoinColumns(master, opts.Columns, mKeyIdxs)
+	if err != nil {
+		msg := "master column resolution: " + err.Error()
+		res.Error = &msg
+		return res, errors.New(msg)
+	}
+	index, err := buildJoinIndex(opts.CrossRefMultiOptions, master, mKeyIdxs)
+	if err != nil {
+		msg := err.Error()
+		res.Error = &msg
+		return res, err
+	}
+
+	totals := map[string]int{}
+	perList := make([]PerJoinResult, 0, len(req.Datasets.Lists))
+	for _, named := range req.Datasets.Lists {
+		pl := joinList(opts, index, master, mKeyIdxs, cols, named)
+		totals["processed_total"] += pl.Processed
+		totals["matched_total"] += pl.Matched
+		totals["missing_total"] += pl.Missing
+		totals["duplicated_total"] += pl.Duplicated
+		totals["master_only_total"] += pl.MasterOnly
+		totals["result_total"] += len(pl.Result.Rows)
+		perList = append(perList, pl)
+	}
+
+	res.PerList = perList
+	res.Summary = totals
+	res.Summary["master_count"] = len(master.Rows)
+	res.Summary["lists_count"] = len(req.Datasets.Lists)
+	res.Summary["duration_ms"] = int(time.Since(start).Milliseconds())
+	res.Error = nil
+	return res, nil
+}
+
+// joinList joins one list with the master.
+func joinList(opts JoinOptions, ix *joinIndex, master types.TableData, mKeyIdxs, cols []int, named types.NamedTable) PerJoinResult {
+	pl := PerJoinResult{Name: named.Name}
+	list := named.Table
+
+	lKeyIdxs, err := resolveListKeys(opts.CrossRefMultiOptions, list, listKeysFor(opts.CrossRefMultiOptions, named.ListKey, named.ListKeys))
+	if err != nil {
+		msg := "list key resolution: " + err.Error()
+		pl.Error = &msg
+		return pl
+	}
+	suffix := opts.Suffix
+	if suffix == "" {
+		suffix = DefaultJoinSuffix
+	}
+	width := tableWidth(list)
+	var header []string
+	if list.HasHeader {
+		header = joinHeader(list, master, width, cols, suffix)
+	}
+	rows := [][]string{}
+	keepUnmatched := opts.Type == "" || opts.Type == JoinLeft || opts.Type == JoinFull
+	used := make([]bool, len(ix.rows))
+
+	for _, row := range list.Rows {
+		pl.Processed++
+		key := utils.CompositeKey(row, lKeyIdxs, ix.normalize)
+		g, ok := -1, false
+		if ix.fuzzy != nil {
+			var fm FuzzyMatch
+			fm, g = ix.fuzzy.match(pl.Processed, key, keyDisplay(row, lKeyIdxs))
+			pl.Matches = append(pl.Matches, fm)
+			ok = g >= 0
+		} else {
+			g, ok = ix.groups[key]
+		}
+		if !ok {
+			pl.Missing++
+			if keepUnmatched {
+				rows = append(rows, joinRow(row, width, nil, cols))
+			}
+			continue
+		}
+		pl.Matched++
+		used[g] = true
+		mrows := ix.rows[g]
+		if len(mrows) > 1 {
+			pl.Duplicated++
+			switch opts.Duplicates {
+			case DuplicatesError:
+				msg := fmt.Sprintf("row %d: key '%s' matches %d master rows", pl.Processed, keyDisplay(row, lKeyIdxs), len(mrows))
+				pl.Error = &msg
+				return pl
+			case DuplicatesLast:
+				mrows = mrows[len(mrows)-1:]
+			case DuplicatesAll:
+			default:
+				mrows = mrows[:1]
+			}
+		}
+		for _, m := range mrows {
+			rows = append(rows, joinRow(row, width, master.Rows[m], cols))
+		}
+	}
+
+	// master rows no list row matched; their key goes into the list key columns
+	for i, mrow := range master.Rows {
+		if g := ix.rowGroup[i]; g >= 0 && used[g] {
+			continue
+		}
+		pl.MasterOnly++
+		if opts.Type != JoinRight && opts.Type != JoinFull {
+			continue
+		}
+		out := joinRow(nil, width, mrow, cols)
+		for k, li := range lKeyIdxs {
+			if li < width {
+				out[li] = cellAt(mrow, mKeyIdxs[k])
+			}
+		}
+		rows = append(rows, out)
+	}
+
+	pl.Result = types.TableData{HasHeader: list.HasHeader, Header: header, Rows: rows}
+	return pl
+}
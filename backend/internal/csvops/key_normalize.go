@@ -0,0 +1,153 @@
+package csvops
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/utils"
+)
+
+// Key normalizers rewrite key cells before they are compared, so values that are
+// written differently but mean the same thing match. They run in the order given,
+// before the trim_spaces and case-insensitive folding of the match options.
+
+type KeyNormalizer string
+
+const (
+	NormTrim               KeyNormalizer = "trim"                 // trim and collapse whitespace
+	NormLower              KeyNormalizer = "lower"                // lowercase
+	NormNFKC               KeyNormalizer = "nfkc"                 // Unicode NFKC (full-width forms, ligatures, ...)
+	NormStripDiacritics    KeyNormalizer = "strip_diacritics"     // "Müller" -> "Muller"
+	NormStripPunctuation   KeyNormalizer = "strip_punctuation"    // drop punctuation characters
+	NormDigitsOnly         KeyNormalizer = "digits_only"          // keep digits only ("+44 (20) 7946-0018" -> "442079460018")
+	NormStripLeadingZeros  KeyNormalizer = "strip_leading_zeros"  // "000123" -> "123"
+	NormStripLegalSuffixes KeyNormalizer = "strip_legal_suffixes" // "Acme Holdings, Inc." -> "Acme Holdings"
+	NormEmail              KeyNormalizer = "email"                // lowercase, drop +tags; gmail: drop dots
+)
+
+var keyNormalizers = map[KeyNormalizer]func(string) string{
+	NormTrim:               utils.WhitespaceTrimmer,
+	NormLower:              strings.ToLower,
+	NormNFKC:               norm.NFKC.String,
+	NormStripDiacritics:    stripDiacritics,
+	NormStripPunctuation:   func(s string) string { return strings.Map(dropRune(unicode.IsPunct), s) },
+	NormDigitsOnly:         func(s string) string { return strings.Map(keepRune(unicode.IsDigit), s) },
+	NormStripLeadingZeros:  stripLeadingZeros,
+	NormStripLegalSuffixes: stripLegalSuffixes,
+	NormEmail:              canonicalEmail,
+}
+
+// KeyNormalizerNames returns the accepted normalizer names.
+func KeyNormalizerNames() []string {
+	names := make([]string, 0, len(keyNormalizers))
+	for name := range keyNormalizers {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newKeyNormalizer returns the function that turns a key cell into its comparison
+// form: the named normalizers in order, then trimming and case folding per flags.
+func newKeyNormalizer(names []KeyNormalizer, trim bool, caseInsensitive bool) (func(string) string, error) {
+	steps := make([]func(string) string, 0, len(names))
+	for _, name := range names {
+		fn, ok := keyNormalizers[KeyNormalizer(strings.ToLower(strings.TrimSpace(string(name))))]
+		if !ok {
+			return nil, fmt.Errorf("unknown normalizer '%s'. available normalizers: [%s]", name, strings.Join(KeyNormalizerNames(), ", "))
+		}
+		steps = append(steps, fn)
+	}
+	return func(val string) string {
+		for _, fn := range steps {
+			val = fn(val)
+		}
+		return utils.Normalize(val, trim, caseInsensitive)
+	}, nil
+}
+
+func dropRune(drop func(rune) bool) func(rune) rune {
+	return func(r rune) rune {
+		if drop(r) {
+			return -1
+		}
+		return r
+	}
+}
+
+func keepRune(keep func(rune) bool) func(rune) rune {
+	return func(r rune) rune {
+		if keep(r) {
+			return r
+		}
+		return -1
+	}
+}
+
+// stripDiacritics removes combining marks after canonical decomposition.
+func stripDiacritics(s string) string {
+	return norm.NFC.String(strings.Map(dropRune(func(r rune) bool { return unicode.Is(unicode.Mn, r) }), norm.NFD.String(s)))
+}
+
+// stripLeadingZeros removes leading zeros, keeping one zero for an all-zero value.
+func stripLeadingZeros(s string) string {
+	t := strings.TrimSpace(s)
+	out := strings.TrimLeft(t, "0")
+	if out == "" && t != "" {
+		return "0"
+	}
+	return out
+}
+
+// legalSuffixes are company legal forms, compared without dots and case.
+var legalSuffixes = map[string]bool{
+	"ltd": true, "limited": true, "plc": true, "llp": true, "lp": true,
+	"inc": true, "incorporated": true, "corp": true, "corporation": true, "co": true, "company": true,
+	"llc": true, "pllc": true, "pty": true, "pte": true,
+	"gmbh": true, "ag": true, "kg": true, "ug": true, "ev": true,
+	"sa": true, "sas": true, "sarl": true, "srl": true, "spa": true, "sl": true,
+	"bv": true, "nv": true, "ab": true, "oy": true, "as": true, "asa": true, "aps": true,
+}
+
+// stripLegalSuffixes removes trailing legal-form words ("Ltd", "Inc.", "GmbH",
+// "S.A.", ...) and the commas before them; at least one word is kept.
+func stripLegalSuffixes(s string) string {
+	words := strings.Fields(s)
+	for len(words) > 1 {
+		w := strings.ToLower(strings.NewReplacer(".", "", ",", "").Replace(words[len(words)-1]))
+		if !legalSuffixes[w] {
+			break
+		}
+		words = words[:len(words)-1]
+	}
+	if len(words) == 0 {
+		return s
+	}
+	words[len(words)-1] = strings.TrimRight(words[len(words)-1], ",")
+	return strings.Join(words, " ")
+}
+
+// canonicalEmail lowercases an address and drops its +tag; Gmail addresses also
+// lose the dots of the local part and googlemail.com becomes gmail.com.
+func canonicalEmail(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	at := strings.LastIndex(s, "@")
+	if at < 0 {
+		return s
+	}
+	local, domain := s[:at], s[at+1:]
+	if plus := strings.Index(local, "+"); plus >= 0 {
+		local = local[:plus]
+	}
+	if domain == "googlemail.com" {
+		domain = "gmail.com"
+	}
+	if domain == "gmail.com" {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	return local + "@" + domain
+}
@@ -0,0 +1,92 @@
+package csvops
+
+import "testing"
+
+func TestStripLegalSuffixes(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Acme, Inc.", "Acme"},
+		{"Acme Holdings Ltd", "Acme Holdings"},
+		{"Acme Co., Ltd.", "Acme"},
+		{"Acme S.A.", "Acme"},
+		{"Acme Ltd,", "Acme"}, // comma after the suffix
+		{"Acme,", "Acme"},     // trailing comma without a suffix
+		{"Co", "Co"},          // a suffix alone is kept
+		{"Co Ltd", "Co"},      // so is the last word
+		{"Acme Widgets", "Acme Widgets"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := stripLegalSuffixes(tt.in); got != tt.want {
+			t.Errorf("stripLegalSuffixes(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalEmail(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"John.Doe+news@GoogleMail.com", "johndoe@gmail.com"},
+		{"j.doe@gmail.com", "jdoe@gmail.com"},
+		{" J.Doe+billing@Example.com ", "j.doe@example.com"}, // dots only dropped for gmail
+		{"Not An+Email", "not an+email"},                     // no @: only lowercased
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := canonicalEmail(tt.in); got != tt.want {
+			t.Errorf("canonicalEmail(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestStripLeadingZeros(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"000123", "123"},
+		{" 0070 ", "70"},
+		{"000", "0"},
+		{"0", "0"},
+		{"", ""},
+		{"   ", ""},
+		{"A01", "A01"},
+	}
+	for _, tt := range tests {
+		if got := stripLeadingZeros(tt.in); got != tt.want {
+			t.Errorf("stripLeadingZeros(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNewKeyNormalizer(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []KeyNormalizer
+		trim  bool
+		ci    bool
+		in    string
+		want  string
+	}{
+		// normalizers run in the order given
+		{"digits then zeros", []KeyNormalizer{NormDigitsOnly, NormStripLeadingZeros}, false, false, "0A07", "7"},
+		{"zeros then digits", []KeyNormalizer{NormStripLeadingZeros, NormDigitsOnly}, false, false, "0A07", "07"},
+		// trimming comes after them, so it also tidies what they leave behind
+		{"trim after", []KeyNormalizer{NormStripPunctuation}, true, false, " . a  b", "a b"},
+		{"no trim", []KeyNormalizer{NormStripPunctuation}, false, false, " . a", "  a"},
+		// and so does case folding
+		{"fold after", []KeyNormalizer{NormStripDiacritics}, false, true, "MÜLLER", "muller"},
+		{"names are trimmed and folded", []KeyNormalizer{" Email "}, false, false, "A+x@b.com", "a@b.com"},
+		{"no normalizers", nil, true, true, "  Acme  Ltd ", "acme ltd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn, err := newKeyNormalizer(tt.names, tt.trim, tt.ci)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fn(tt.in); got != tt.want {
+				t.Errorf("normalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := newKeyNormalizer([]KeyNormalizer{"soundex"}, false, false); err == nil {
+		t.Error("unknown normalizer accepted")
+	}
+}
@@ -0,0 +1,124 @@
+package csvops
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/utils"
+)
+
+// Batch lookups: OneToMany and ManyToOne can look up a list of values at once,
+// given inline or as a column of another table, instead of a single target value.
+
+// LookupValueColumn is added to the combined results of a batch lookup; it holds
+// the lookup value each row matched.
+const LookupValueColumn = "lookup_value"
+
+// LookupSource takes the lookup values from a column of another table, e.g. a
+// list of device names under investigation.
+type LookupSource struct {
+	Table   types.TableData `json:"table"`
+	Column  string          `json:"column"`            // header name or numeric index string
+	Columns []string        `json:"columns,omitempty"` // composite keys: one per key, in key order; overrides Column
+}
+
+// LookupHits reports the hits of one batch lookup value.
+type LookupHits struct {
+	Value   string         `json:"value"`            // as given (composite parts joined with " | ")
+	Matched int            `json:"matched"`          // rows matching the value across every table searched
+	Tables  map[string]int `json:"tables,omitempty"` // one_to_many: matching rows per table ("master" and the list names)
+}
+
+// lookupSet indexes the batch lookup values by normalized key. Values that
+// normalize to the same key are looked up once, under their first spelling.
+type lookupSet struct {
+	hits  []LookupHits
+	index map[string]int
+}
+
+// lookupRows gathers the batch lookup values, inline values first and then the
+// rows of from, as rows with one cell per key.
+func lookupRows(values []string, from *LookupSource, nkeys int) ([][]string, error) {
+	var rows [][]string
+	if len(values) > 0 {
+		if nkeys != 1 {
+			return nil, errors.New("inline lookup values need a single key; use lookup_from with one column per key")
+		}
+		for _, v := range values {
+			rows = append(rows, []string{v})
+		}
+	}
+	if from != nil {
+		cols := from.Columns
+		if len(cols) == 0 {
+			cols = []string{from.Column}
+		}
+		if len(cols) != nkeys {
+			return nil, fmt.Errorf("lookup_from has %d columns, target has %d keys", len(cols), nkeys)
+		}
+		idxs, err := utils.ResolveKeyIndices(from.Table, cols)
+		if err != nil {
+			return nil, errors.New("lookup column resolution: " + err.Error())
+		}
+		for _, r := range from.Table.Rows {
+			row := make([]string, len(idxs))
+			for i, idx := range idxs {
+				row[i] = cellAt(r, idx)
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// newLookupSet indexes lookup rows (one cell per key). Empty values are skipped.
+func newLookupSet(rows [][]string, nkeys int, normalize func(string) string) (*lookupSet, error) {
+	set := &lookupSet{hits: []LookupHits{}, index: map[string]int{}}
+	all := seqIndices(nkeys)
+	empty := utils.CompositeKey(nil, all, normalize)
+	for _, row := range rows {
+		key := utils.CompositeKey(row, all, normalize)
+		if _, dup := set.index[key]; dup || key == empty {
+			continue
+		}
+		set.index[key] = len(set.hits)
+		set.hits = append(set.hits, LookupHits{Value: keyDisplay(row, all)})
+	}
+	if len(set.hits) == 0 {
+		return nil, errors.New("no lookup values")
+	}
+	return set, nil
+}
+
+// find returns the position of the lookup value with the normalized key, or -1.
+func (s *lookupSet) find(key string) int {
+	if i, ok := s.index[key]; ok {
+		return i
+	}
+	return -1
+}
+
+// hit counts a row of the named table matching lookup value i.
+func (s *lookupSet) hit(i int, table string) {
+	h := &s.hits[i]
+	h.Matched++
+	if table == "" {
+		return
+	}
+	if h.Tables == nil {
+		h.Tables = map[string]int{}
+	}
+	h.Tables[table]++
+}
+
+// noHits returns the lookup values no row matched.
+func (s *lookupSet) noHits() []string {
+	out := []string{}
+	for _, h := range s.hits {
+		if h.Matched == 0 {
+			out = append(out, h.Value)
+		}
+	}
+	return out
+}
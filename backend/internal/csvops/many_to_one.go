@@ -3,6 +3,7 @@ package csvops
 import (
 	"encoding/json"
 	"errors"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,14 +12,44 @@ import (
 )
 
 type ManyToOneOptions struct {
-	MatchMethod MatchMethod `json:"match_method"` // "exact" | "case_insensitive"
-	TrimSpaces  bool        `json:"trim_spaces"`
+	MatchMethod MatchMethod     `json:"match_method"` // "exact" | "case_insensitive"
+	TrimSpaces  bool            `json:"trim_spaces"`
+	Normalizers []KeyNormalizer `json:"normalizers,omitempty"` // applied to one_key and many_key cells and the value
+
+	// GroupAll groups every one value instead of only target.value (which is then
+	// not required), e.g. every User with the list of their Devices.
+	GroupAll bool `json:"group_all"`
 }
 
 type ManyToOneTarget struct {
 	OneKey  string `json:"one_key"`  // e.g., "User"
 	ManyKey string `json:"many_key"` // e.g., "Device"
-	Value   string `json:"value"`    // required: user we are filtering by
+	Value   string `json:"value"`    // required unless group_all or a batch lookup: user we are filtering by
+
+	// Batch lookups replace Value: a list of values and/or a column of another table.
+	Lookup     []string      `json:"lookup,omitempty"`
+	LookupFrom *LookupSource `json:"lookup_from,omitempty"`
+}
+
+// batch reports whether the target looks up a list of values.
+func (t ManyToOneTarget) batch() bool {
+	return len(t.Lookup) > 0 || t.LookupFrom != nil
+}
+
+// ManyValue is one distinct value of the many column within a group. Values are
+// compared after normalization, like the one values.
+type ManyValue struct {
+	Value    string `json:"value"`     // as it appears in its first row
+	Count    int    `json:"count"`     // rows with the value
+	FirstRow int    `json:"first_row"` // 1-based data row numbers
+	LastRow  int    `json:"last_row"`
+}
+
+// ManyToOneGroup holds the distinct many values of one one value.
+type ManyToOneGroup struct {
+	One  string      `json:"one"`  // as it appears in its first row
+	Rows int         `json:"rows"` // rows with the one value
+	Many []ManyValue `json:"many"` // in order of first occurrence; empty cells are left out
 }
 
 type ManyToOneRequest struct {
@@ -31,20 +62,52 @@ type ManyToOneRequest struct {
 type ManyToOneResponse struct {
 	Operation  string              `json:"operation"`
 	Summary    types.ResultSummary `json:"summary"`
-	Matched    *types.TableData    `json:"matched"`
+	Matched    *types.TableData    `json:"matched"` // batch lookups add a lookup_value column
+	Groups     []ManyToOneGroup    `json:"groups"`
+	Aggregated *types.TableData    `json:"aggregated"`          // one row per (one, many) pair: one, many, count, first_row, last_row
+	PerValue   []LookupHits        `json:"per_value,omitempty"` // batch lookups: hits per value
+	NoHits     []string            `json:"no_hits,omitempty"`   // batch lookups: values without a hit
 	Error      *string             `json:"error"`
 	RawRequest json.RawMessage     `json:"-"`
 }
 
-// ManyToOne returns all rows where one_key == value
+// manyGroup builds a ManyToOneGroup, indexing its many values by normalized value.
+type manyGroup struct {
+	ManyToOneGroup
+	index map[string]int
+}
+
+func (g *manyGroup) add(norm, value string, rowNum int) {
+	g.Rows++
+	if norm == "" {
+		return
+	}
+	i, ok := g.index[norm]
+	if !ok {
+		i = len(g.Many)
+		g.index[norm] = i
+		g.Many = append(g.Many, ManyValue{Value: value, FirstRow: rowNum})
+	}
+	g.Many[i].Count++
+	g.Many[i].LastRow = rowNum
+}
+
+// ManyToOne returns all rows where one_key == value, with the distinct values of
+// many_key among them; with group_all it does so for every one value.
 func ManyToOne(req ManyToOneRequest) (ManyToOneResponse, error) {
 	var res ManyToOneResponse
 	res.Operation = req.Operation
 	start := time.Now()
 
 	// Validation
-	if strings.TrimSpace(req.Target.OneKey) == "" || strings.TrimSpace(req.Target.ManyKey) == "" || strings.TrimSpace(req.Target.Value) == "" {
-		msg := "target.one_key, target.many_key, and target.value are required"
+	batch := req.Target.batch()
+	if strings.TrimSpace(req.Target.OneKey) == "" || strings.TrimSpace(req.Target.ManyKey) == "" || (strings.TrimSpace(req.Target.Value) == "" && !req.Options.GroupAll && !batch) {
+		msg := "target.one_key, target.many_key, and target.value (unless group_all or target.lookup) are required"
+		res.Error = &msg
+		return res, errors.New(msg)
+	}
+	if batch && req.Options.GroupAll {
+		msg := "group_all cannot be combined with a batch lookup"
 		res.Error = &msg
 		return res, errors.New(msg)
 	}
@@ -67,22 +130,85 @@ func ManyToOne(req ManyToOneRequest) (ManyToOneResponse, error) {
 		res.Error = &msg
 		return res, errors.New(msg)
 	}
-	_ = manyIdx // kept for validation purposes
+	normalize, err := newKeyNormalizer(req.Options.Normalizers, req.Options.TrimSpaces, req.Options.MatchMethod == MatchCaseInsensitive)
+	if err != nil {
+		msg := err.Error()
+		res.Error = &msg
+		return res, err
+	}
+
+	// the one values to find (unused with group_all)
+	lookupValues := [][]string{{req.Target.Value}}
+	if batch {
+		if lookupValues, err = lookupRows(req.Target.Lookup, req.Target.LookupFrom, 1); err != nil {
+			msg := err.Error()
+			res.Error = &msg
+			return res, err
+		}
+	}
+	var lookups *lookupSet
+	if !req.Options.GroupAll {
+		if lookups, err = newLookupSet(lookupValues, 1, normalize); err != nil {
+			msg := err.Error()
+			res.Error = &msg
+			return res, err
+		}
+	}
 
 	processed := 0
 	matched := 0
-	valNorm := utils.Normalize(req.Target.Value, req.Options.TrimSpaces, req.Options.MatchMethod == MatchCaseInsensitive)
 	outRows := make([][]string, 0)
+	width := tableWidth(req.Dataset) // lookup values go after the widest row
+	groups := []*manyGroup{}
+	byOne := map[string]*manyGroup{}
 
 	for _, row := range req.Dataset.Rows {
 		processed++
 		keyVal := ""
 		if oneIdx < len(row) {
-			keyVal = utils.Normalize(row[oneIdx], req.Options.TrimSpaces, req.Options.MatchMethod == MatchCaseInsensitive)
+			keyVal = normalize(row[oneIdx])
+		}
+		hit := -1
+		if lookups != nil {
+			hit = lookups.find(keyVal)
+		}
+		if (req.Options.GroupAll && keyVal == "") || (!req.Options.GroupAll && hit < 0) {
+			continue
 		}
-		if keyVal == valNorm {
-			matched++
-			outRows = append(outRows, append([]string(nil), row...))
+		matched++
+		out := append([]string(nil), row...)
+		if batch {
+			lookups.hit(hit, "")
+			out = append(padTo(out, width), lookups.hits[hit].Value)
+		}
+		outRows = append(outRows, out)
+
+		g := byOne[keyVal]
+		if g == nil {
+			g = &manyGroup{ManyToOneGroup: ManyToOneGroup{One: cellAt(row, oneIdx), Many: []ManyValue{}}, index: map[string]int{}}
+			byOne[keyVal] = g
+			groups = append(groups, g)
+		}
+		many := cellAt(row, manyIdx)
+		g.add(normalize(many), many, processed)
+	}
+
+	oneName, manyName := "one", "many"
+	if req.Dataset.HasHeader {
+		oneName, manyName = req.Dataset.Header[oneIdx], req.Dataset.Header[manyIdx]
+	}
+	res.Aggregated = &types.TableData{
+		HasHeader: true,
+		Header:    []string{oneName, manyName, "count", "first_row", "last_row"},
+		Rows:      [][]string{},
+	}
+	res.Groups = make([]ManyToOneGroup, 0, len(groups))
+	for _, g := range groups {
+		res.Groups = append(res.Groups, g.ManyToOneGroup)
+		for _, m := range g.Many {
+			res.Aggregated.Rows = append(res.Aggregated.Rows, []string{
+				g.One, m.Value, strconv.Itoa(m.Count), strconv.Itoa(m.FirstRow), strconv.Itoa(m.LastRow),
+			})
 		}
 	}
 
@@ -91,6 +217,13 @@ func ManyToOne(req ManyToOneRequest) (ManyToOneResponse, error) {
 		Header:    append([]string(nil), req.Dataset.Header...),
 		Rows:      outRows,
 	}
+	if batch {
+		if req.Dataset.HasHeader {
+			res.Matched.Header = append(padTo(res.Matched.Header, width), LookupValueColumn)
+		}
+		res.PerValue = lookups.hits
+		res.NoHits = lookups.noHits()
+	}
 	res.Summary = types.ResultSummary{
 		Processed:  processed,
 		Matched:    matched,
@@ -0,0 +1,49 @@
+package csvops
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+)
+
+// Batch lookups put lookup_value after the widest row, also in headerless or
+// ragged tables, so it stays in one column.
+func TestManyToOneLookupColumnLinesUp(t *testing.T) {
+	tests := []struct {
+		name       string
+		dataset    types.TableData
+		wantHeader []string
+		wantRows   [][]string
+	}{
+		{
+			"headerless",
+			types.TableData{Rows: [][]string{{"ann", "pc1"}, {"bob", "pc2", "spare"}}},
+			nil,
+			[][]string{{"ann", "pc1", "", "ann"}, {"bob", "pc2", "spare", "bob"}},
+		},
+		{
+			"rows wider than header",
+			types.TableData{HasHeader: true, Header: []string{"user", "device"}, Rows: [][]string{{"ann", "pc1", "spare"}, {"bob", "pc2"}}},
+			[]string{"user", "device", "", LookupValueColumn},
+			[][]string{{"ann", "pc1", "spare", "ann"}, {"bob", "pc2", "", "bob"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := ManyToOne(ManyToOneRequest{
+				Target:  ManyToOneTarget{OneKey: "0", ManyKey: "1", Lookup: []string{"ann", "bob"}},
+				Dataset: tt.dataset,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(res.Matched.Header, tt.wantHeader) {
+				t.Errorf("header = %q, want %q", res.Matched.Header, tt.wantHeader)
+			}
+			if !reflect.DeepEqual(res.Matched.Rows, tt.wantRows) {
+				t.Errorf("rows = %q, want %q", res.Matched.Rows, tt.wantRows)
+			}
+		})
+	}
+}
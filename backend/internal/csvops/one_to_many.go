@@ -2,6 +2,9 @@ package csvops
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,13 +23,41 @@ type OneToManyRequest struct {
 }
 
 type OneToManyOptions struct {
-	MatchMethod MatchMethod `json:"match_method"` // exact | case_insensitive
-	TrimSpaces  bool        `json:"trim_spaces"`
+	MatchMethod MatchMethod     `json:"match_method"` // exact | case_insensitive
+	TrimSpaces  bool            `json:"trim_spaces"`
+	Normalizers []KeyNormalizer `json:"normalizers,omitempty"` // applied to key cells and target values
+
+	// UnionSchema keeps list columns that map to no master column as extra columns
+	// of the combined table (after the master's), instead of dropping them.
+	UnionSchema bool `json:"union_schema"`
 }
 
 type OneToManyTarget struct {
 	Key   string `json:"key"`   // column name or numeric index string, e.g. "DeviceName"
 	Value string `json:"value"` // value to look up, e.g. "device1"
+
+	// Composite lookups, e.g. keys ["Site", "AssetTag"] with values ["LON", "A-17"].
+	// Lists pair their list_keys with these by position.
+	Keys   []string `json:"keys,omitempty"`   // overrides Key
+	Values []string `json:"values,omitempty"` // overrides Value; one per key
+
+	// Batch lookups replace Value/Values: a list of values (single key) and/or a
+	// column per key of another table.
+	Lookup     []string      `json:"lookup,omitempty"`
+	LookupFrom *LookupSource `json:"lookup_from,omitempty"`
+}
+
+// keys returns the target key columns and values (Keys/Values, else Key/Value).
+func (t OneToManyTarget) keys() ([]string, []string) {
+	if len(t.Keys) > 0 {
+		return t.Keys, t.Values
+	}
+	return []string{t.Key}, []string{t.Value}
+}
+
+// batch reports whether the target looks up a list of values.
+func (t OneToManyTarget) batch() bool {
+	return len(t.Lookup) > 0 || t.LookupFrom != nil
 }
 
 type OneToManyPerList struct {
@@ -42,33 +73,64 @@ type OneToManyResponse struct {
 	Operation string             `json:"operation"`
 	Summary   map[string]int     `json:"summary"`
 	PerList   []OneToManyPerList `json:"per_list"`
-	Combined  types.TableData    `json:"combined"` // aligned to master header + source_list column
+	Combined  types.TableData    `json:"combined"`            // aligned to master header + source_list column (+ lookup_value for batches)
+	PerValue  []LookupHits       `json:"per_value,omitempty"` // batch lookups: hits per value
+	NoHits    []string           `json:"no_hits,omitempty"`   // batch lookups: values without a hit anywhere
 	Error     *string            `json:"error"`
 }
 
-// OneToMany searches master & lists for rows where target.key == target.value.
+// OneToMany searches master & lists for rows where target.key == target.value,
+// or for every value of a batch lookup.
 func OneToMany(req OneToManyRequest) (OneToManyResponse, error) {
 	var res OneToManyResponse
 	res.Operation = req.Operation
 	start := time.Now()
 
 	// validate
-	if strings.TrimSpace(req.Target.Key) == "" || strings.TrimSpace(req.Target.Value) == "" {
-		msg := "target.key and target.value are required"
+	targetKeys, targetValues := req.Target.keys()
+	batch := req.Target.batch()
+	if !batch && len(targetKeys) != len(targetValues) {
+		msg := fmt.Sprintf("target has %d keys but %d values", len(targetKeys), len(targetValues))
 		res.Error = &msg
 		return res, errors.New(msg)
 	}
+	for i := range targetKeys {
+		if strings.TrimSpace(targetKeys[i]) == "" || (!batch && strings.TrimSpace(targetValues[i]) == "") {
+			msg := "target.key and target.value (or target.lookup) are required"
+			res.Error = &msg
+			return res, errors.New(msg)
+		}
+	}
 	if req.Datasets.Master.Rows == nil {
 		msg := "master dataset required"
 		res.Error = &msg
 		return res, errors.New(msg)
 	}
 
-	// Normalize target value per options
-	targetNorm := utils.Normalize(req.Target.Value, req.Options.TrimSpaces, req.Options.MatchMethod == MatchCaseInsensitive)
+	// Normalize the lookup values per options (each value forms a row of its own)
+	normalize, err := newKeyNormalizer(req.Options.Normalizers, req.Options.TrimSpaces, req.Options.MatchMethod == MatchCaseInsensitive)
+	if err != nil {
+		msg := err.Error()
+		res.Error = &msg
+		return res, err
+	}
+	lookupValues := [][]string{targetValues}
+	if batch {
+		if lookupValues, err = lookupRows(req.Target.Lookup, req.Target.LookupFrom, len(targetKeys)); err != nil {
+			msg := err.Error()
+			res.Error = &msg
+			return res, err
+		}
+	}
+	lookups, err := newLookupSet(lookupValues, len(targetKeys), normalize)
+	if err != nil {
+		msg := err.Error()
+		res.Error = &msg
+		return res, err
+	}
 
-	// Resolve master key index
-	mKeyIdx, err := utils.ResolveKeyIndex(req.Datasets.Master, req.Target.Key)
+	// Resolve master key indices
+	mKeyIdxs, err := utils.ResolveKeyIndices(req.Datasets.Master, targetKeys)
 	if err != nil {
 		headers := strings.Join(req.Datasets.Master.Header, ", ")
 		msg := "master key resolution: " + err.Error() + ". available master headers: [" + headers + "]"
@@ -76,18 +138,20 @@ func OneToMany(req OneToManyRequest) (OneToManyResponse, error) {
 		return res, errors.New(msg)
 	}
 
-	// 1) Search master for matches
+	// 1) Search master for matches; hits holds the lookup value of each matched row
 	masterMatches := [][]string{}
+	masterHits := []int{}
 	masterProcessed := 0
 	for _, row := range req.Datasets.Master.Rows {
 		masterProcessed++
-		var keyVal string
-		if mKeyIdx < len(row) {
-			keyVal = utils.Normalize(row[mKeyIdx], req.Options.TrimSpaces, req.Options.MatchMethod == MatchCaseInsensitive)
+		if !hasKeyCells(row, mKeyIdxs) {
+			continue
 		}
-		if keyVal == targetNorm {
+		if i := lookups.find(utils.CompositeKey(row, mKeyIdxs, normalize)); i >= 0 {
 			// keep entire master row as-is
 			masterMatches = append(masterMatches, append([]string(nil), row...))
+			masterHits = append(masterHits, i)
+			lookups.hit(i, "master")
 		}
 	}
 
@@ -111,14 +175,11 @@ func OneToMany(req OneToManyRequest) (OneToManyResponse, error) {
 	// 2) For each named list, search for matches and build per-list result
 	totalProcessed := masterProcessed
 	totalMatched := len(masterMatches)
-	combinedRows := [][]string{}
-	// We'll build combined rows later; first add master rows with source "master"
-	// Combined header will be master.Header + "source_list"
-	for _, r := range masterMatches {
-		combinedRows = append(combinedRows, append([]string(nil), r...)) // will add source later when header is built
-	}
+	listHits := make([][]int, len(req.Datasets.Lists))
+	schema := newCombinedSchema(req.Datasets.Master, req.Options.UnionSchema)
+	listCols := make([][]int, len(req.Datasets.Lists))
 
-	for _, named := range req.Datasets.Lists {
+	for li, named := range req.Datasets.Lists {
 		pl := OneToManyPerList{
 			Name:      named.Name,
 			Processed: 0,
@@ -132,13 +193,19 @@ func OneToMany(req OneToManyRequest) (OneToManyResponse, error) {
 			Error: nil,
 		}
 
-		// determine list key (per-list override -> master key)
-		listKey := strings.TrimSpace(named.ListKey)
-		if listKey == "" {
-			listKey = req.Target.Key
+		// determine list keys (per-list override -> master keys)
+		listKeys := named.ListKeys
+		if len(listKeys) == 0 && strings.TrimSpace(named.ListKey) != "" {
+			listKeys = []string{named.ListKey}
+		}
+		if len(listKeys) == 0 {
+			listKeys = targetKeys
 		}
 
-		lKeyIdx, lerr := utils.ResolveKeyIndex(named.Table, listKey)
+		lKeyIdxs, lerr := utils.ResolveKeyIndices(named.Table, listKeys)
+		if lerr == nil && len(lKeyIdxs) != len(mKeyIdxs) {
+			lerr = fmt.Errorf("list has %d key columns, target has %d", len(lKeyIdxs), len(mKeyIdxs))
+		}
 		if lerr != nil {
 			headers := strings.Join(named.Table.Header, ", ")
 			msg := "list key resolution: " + lerr.Error() + ". available headers for list '" + named.Name + "': [" + headers + "]"
@@ -146,78 +213,71 @@ func OneToMany(req OneToManyRequest) (OneToManyResponse, error) {
 			perList = append(perList, pl)
 			continue
 		}
+		if listCols[li], lerr = schema.mapList(named.Table, named.ColumnMap); lerr != nil {
+			msg := "column mapping: " + lerr.Error()
+			pl.Error = &msg
+			perList = append(perList, pl)
+			continue
+		}
 
 		// scan rows
 		for _, row := range named.Table.Rows {
 			pl.Processed++
 			totalProcessed++
-			var keyVal string
-			if lKeyIdx < len(row) {
-				keyVal = utils.Normalize(row[lKeyIdx], req.Options.TrimSpaces, req.Options.MatchMethod == MatchCaseInsensitive)
+			if !hasKeyCells(row, lKeyIdxs) {
+				continue
 			}
-			if keyVal == targetNorm {
+			if i := lookups.find(utils.CompositeKey(row, lKeyIdxs, normalize)); i >= 0 {
 				pl.Matched++
 				totalMatched++
 				// keep original list row in per-list result
 				pl.Result.Rows = append(pl.Result.Rows, append([]string(nil), row...))
-				// store list row for combined output (we'll map to master header later)
-				combinedRows = append(combinedRows, append([]string(nil), row...))
+				listHits[li] = append(listHits[li], i)
+				lookups.hit(i, named.Name)
 			}
 		}
 
 		perList = append(perList, pl)
 	}
 
-	// 3) Build combined TableData aligned to master header + source_list
-	combinedHeader := append([]string(nil), req.Datasets.Master.Header...)
+	// 3) Build combined TableData aligned to master header (+ extra list columns) + source_list (+ lookup_value)
+	combinedHeader := append([]string(nil), schema.header...)
 	combinedHeader = append(combinedHeader, "source_list")
-
-	combinedMappedRows := make([][]string, 0, len(combinedRows))
-	// helper: build a map from list header name (lower trimmed) -> index
-	mapMasterHeader := map[string]int{}
-	for i, h := range req.Datasets.Master.Header {
-		mapMasterHeader[strings.ToLower(strings.TrimSpace(h))] = i
+	sourceCol := len(combinedHeader) - 1
+	if batch {
+		combinedHeader = append(combinedHeader, LookupValueColumn)
 	}
 
+	combinedMappedRows := [][]string{}
+
 	// First, add master matches mapped directly (source "master")
-	for _, r := range masterMatches {
+	for ri, r := range masterMatches {
 		mapped := make([]string, len(combinedHeader))
-		// copy values for master header columns (they align)
-		for i := 0; i < len(req.Datasets.Master.Header) && i < len(r); i++ {
-			mapped[i] = r[i]
+		// copy values for master columns (they align)
+		copy(mapped[:schema.masterWidth], r)
+		mapped[sourceCol] = "master"
+		if batch {
+			mapped[sourceCol+1] = lookups.hits[masterHits[ri]].Value
 		}
-		mapped[len(combinedHeader)-1] = "master"
 		combinedMappedRows = append(combinedMappedRows, mapped)
 	}
 
-	// Then, add list matches: need to map list headers to master header indices
-	for _, named := range req.Datasets.Lists {
-		// build header map for this list
-		listHeaderMap := map[string]int{}
-		for i, h := range named.Table.Header {
-			listHeaderMap[strings.ToLower(strings.TrimSpace(h))] = i
-		}
-		// for each matched row in perList for this named list, find those entries
-		// find the perList entry for named.Name
-		var rowsForList [][]string
-		for _, p := range perList {
-			if p.Name == named.Name {
-				rowsForList = p.Result.Rows
-				break
-			}
-		}
-		for _, r := range rowsForList {
+	// Then, add list matches through their column mapping
+	for li, named := range req.Datasets.Lists {
+		// the perList entry for this list follows the master's
+		rowsForList := perList[li+1].Result.Rows
+		for ri, r := range rowsForList {
 			mapped := make([]string, len(combinedHeader))
-			// map each list column to master column if name matches (case-insensitive)
-			for lname, lidx := range listHeaderMap {
-				if midx, ok := mapMasterHeader[lname]; ok {
-					if lidx < len(r) {
-						mapped[midx] = r[lidx]
-					}
+			for lidx, cidx := range listCols[li] {
+				if cidx >= 0 && lidx < len(r) {
+					mapped[cidx] = r[lidx]
 				}
 			}
 			// set source_list
-			mapped[len(combinedHeader)-1] = named.Name
+			mapped[sourceCol] = named.Name
+			if batch {
+				mapped[sourceCol+1] = lookups.hits[listHits[li][ri]].Value
+			}
 			combinedMappedRows = append(combinedMappedRows, mapped)
 		}
 	}
@@ -238,6 +298,139 @@ func OneToMany(req OneToManyRequest) (OneToManyResponse, error) {
 		"total_matched":    totalMatched,
 		"duration_ms":      int(time.Since(start).Milliseconds()),
 	}
+	if batch {
+		res.PerValue = lookups.hits
+		res.NoHits = lookups.noHits()
+		res.Summary["lookup_values"] = len(lookups.hits)
+		res.Summary["lookup_no_hits"] = len(res.NoHits)
+	}
 	res.Error = nil
 	return res, nil
 }
+
+// combinedSchema lays out the columns of the combined table: the master's, then
+// with union_schema the list columns that map to none of them, each added once.
+type combinedSchema struct {
+	master      types.TableData
+	masterWidth int
+	union       bool
+	header      []string
+	byName      map[string]int // lower-cased trimmed name -> combined column
+	displaced   map[string]int // same, for list columns whose master column a column_map entry took
+}
+
+func newCombinedSchema(master types.TableData, union bool) *combinedSchema {
+	s := &combinedSchema{master: master, masterWidth: tableWidth(master), union: union, byName: map[string]int{}, displaced: map[string]int{}}
+	for i := 0; i < s.masterWidth; i++ {
+		name := positionalName(i)
+		if master.HasHeader && i < len(master.Header) {
+			name = master.Header[i]
+		}
+		s.column(name)
+	}
+	return s
+}
+
+// positionalName names a column of a headerless table.
+func positionalName(i int) string {
+	return "column_" + strconv.Itoa(i)
+}
+
+// column returns the combined column with the given name, adding it when new.
+func (s *combinedSchema) column(name string) int {
+	k := strings.ToLower(strings.TrimSpace(name))
+	if c, ok := s.byName[k]; ok {
+		return c
+	}
+	s.byName[k] = len(s.header)
+	s.header = append(s.header, name)
+	return len(s.header) - 1
+}
+
+// displacedColumn returns the extra column for a list column whose master column
+// was taken by a column_map entry, adding it under a free name ("ip_2") when new.
+func (s *combinedSchema) displacedColumn(name string) int {
+	k := strings.ToLower(strings.TrimSpace(name))
+	if c, ok := s.displaced[k]; ok {
+		return c
+	}
+	unique := name
+	for n := 2; ; n++ {
+		if _, taken := s.byName[strings.ToLower(strings.TrimSpace(unique))]; !taken {
+			break
+		}
+		unique = name + "_" + strconv.Itoa(n)
+	}
+	c := s.column(unique)
+	s.displaced[k] = c
+	return c
+}
+
+// mapList returns the combined column of every list column (-1 drops it). Columns
+// in columnMap go to the master column named there; the others match a master
+// column by header name, or by position when either table has no header, and
+// otherwise become extra columns with union_schema (as do columns whose master
+// column a columnMap entry took).
+func (s *combinedSchema) mapList(list types.TableData, columnMap map[string]string) ([]int, error) {
+	cols := make([]int, tableWidth(list))
+	for i := range cols {
+		cols[i] = -1
+	}
+	claimed := map[int]bool{}
+	from := make([]string, 0, len(columnMap))
+	for lc := range columnMap {
+		from = append(from, lc)
+	}
+	sort.Strings(from)
+	for _, lc := range from {
+		li, err := utils.ResolveKeyIndex(list, lc)
+		if err != nil {
+			return nil, fmt.Errorf("list column '%s': %w", lc, err)
+		}
+		mi, err := utils.ResolveKeyIndex(s.master, columnMap[lc])
+		if err != nil {
+			return nil, fmt.Errorf("master column '%s': %w", columnMap[lc], err)
+		}
+		if li < len(cols) && mi < s.masterWidth {
+			cols[li] = mi
+			claimed[mi] = true
+		}
+	}
+
+	byName := list.HasHeader && s.master.HasHeader
+	for i := range cols {
+		if cols[i] >= 0 {
+			continue
+		}
+		name := positionalName(i)
+		if list.HasHeader && i < len(list.Header) {
+			name = list.Header[i]
+		}
+		c := -1
+		if byName {
+			if mc, ok := s.byName[strings.ToLower(strings.TrimSpace(name))]; ok && mc < s.masterWidth {
+				c = mc
+			}
+		} else if i < s.masterWidth {
+			c = i
+		}
+		switch {
+		case c >= 0 && !claimed[c]:
+			cols[i] = c
+		case c < 0 && s.union:
+			cols[i] = s.column(name)
+		case s.union:
+			cols[i] = s.displacedColumn(name)
+		}
+	}
+	return cols, nil
+}
+
+// seqIndices returns [0, 1, ..., n-1].
+func seqIndices(n int) []int {
+	idxs := make([]int, n)
+	for i := range idxs {
+		idxs[i] = i
+	}
+	return idxs
+}
@@ -0,0 +1,44 @@
+package csvops
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+)
+
+func TestCombinedSchemaMapList(t *testing.T) {
+	master := types.TableData{HasHeader: true, Header: []string{"DeviceName", "IP"}}
+	list := types.TableData{HasHeader: true, Header: []string{"hostname", "DeviceName", "owner"}}
+	columnMap := map[string]string{"hostname": "DeviceName"}
+
+	tests := []struct {
+		name       string
+		union      bool
+		wantCols   []int
+		wantHeader []string
+	}{
+		{"drop", false, []int{0, -1, -1}, []string{"DeviceName", "IP"}},
+		{"union", true, []int{0, 2, 3}, []string{"DeviceName", "IP", "DeviceName_2", "owner"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newCombinedSchema(master, tt.union)
+			cols, err := s.mapList(list, columnMap)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// a second list with the same clash reuses the extra column
+			again, err := s.mapList(list, columnMap)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cols, tt.wantCols) || !reflect.DeepEqual(again, tt.wantCols) {
+				t.Errorf("cols = %v, then %v, want %v", cols, again, tt.wantCols)
+			}
+			if !reflect.DeepEqual(s.header, tt.wantHeader) {
+				t.Errorf("header = %v, want %v", s.header, tt.wantHeader)
+			}
+		})
+	}
+}
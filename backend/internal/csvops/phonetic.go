@@ -0,0 +1,614 @@
+package csvops
+
+import (
+	"strings"
+	"unicode"
+)
+
+// phoneticCodes returns the codes a key is indexed and looked up under: the
+// per-word codes joined by spaces (for metaphone, once with the primary and once
+// with the alternate codes).
+func phoneticCodes(method MatchMethod, text string) []string {
+	ws := words(strings.ToUpper(text))
+	if len(ws) == 0 {
+		return nil
+	}
+	primary := make([]string, 0, len(ws))
+	alternate := make([]string, 0, len(ws))
+	for _, w := range ws {
+		if method == MatchSoundex {
+			primary = append(primary, soundex(w))
+			continue
+		}
+		p, a := doubleMetaphone(w)
+		primary = append(primary, p)
+		alternate = append(alternate, a)
+	}
+	codes := []string{strings.Join(primary, " ")}
+	if method == MatchMetaphone {
+		if alt := strings.Join(alternate, " "); alt != codes[0] {
+			codes = append(codes, alt)
+		}
+	}
+	return codes
+}
+
+// soundex returns the American Soundex code of an upper-case word ("ROBERT" ->
+// "R163"). Letters outside A-Z are ignored; a word without any yields "".
+func soundex(word string) string {
+	const codes = "01230120022455012623010202" // A..Z
+	var out []byte
+	var last byte
+	for _, r := range word {
+		if r < 'A' || r > 'Z' {
+			continue
+		}
+		c := codes[r-'A']
+		if len(out) == 0 {
+			out = append(out, byte(r))
+			last = c
+			continue
+		}
+		switch {
+		case r == 'H' || r == 'W':
+			// do not separate letters with the same code
+		case c == '0':
+			last = 0
+		case c != last:
+			out = append(out, c)
+			last = c
+		}
+		if len(out) == 4 {
+			break
+		}
+	}
+	if len(out) == 0 {
+		return ""
+	}
+	for len(out) < 4 {
+		out = append(out, '0')
+	}
+	return string(out[:4])
+}
+
+// doubleMetaphone returns the primary and alternate Double Metaphone codes of an
+// upper-case word (Lawrence Philips' algorithm, codes truncated to 4 characters).
+func doubleMetaphone(word string) (string, string) {
+	m := newMetaphone(word)
+	m.run()
+	p, a := m.primary.String(), m.alternate.String()
+	if len(p) > 4 {
+		p = p[:4]
+	}
+	if len(a) > 4 {
+		a = a[:4]
+	}
+	return p, a
+}
+
+type metaphone struct {
+	w                  []rune // word padded with spaces so look-aheads never run off the end
+	length, last       int
+	primary, alternate strings.Builder
+	slavoGermanic      bool
+}
+
+func newMetaphone(word string) *metaphone {
+	var rs []rune
+	for _, r := range word {
+		if unicode.IsLetter(r) {
+			rs = append(rs, unicode.ToUpper(r))
+		}
+	}
+	s := string(rs)
+	m := &metaphone{w: append(rs, []rune("     ")...), length: len(rs), last: len(rs) - 1}
+	m.slavoGermanic = strings.ContainsAny(s, "WK") || strings.Contains(s, "CZ") || strings.Contains(s, "WITZ")
+	return m
+}
+
+func (m *metaphone) at(i int) rune {
+	if i < 0 || i >= len(m.w) {
+		return 0
+	}
+	return m.w[i]
+}
+
+// is reports whether the n runes at start equal one of opts.
+func (m *metaphone) is(start, n int, opts ...string) bool {
+	if start < 0 || start+n > len(m.w) {
+		return false
+	}
+	sub := string(m.w[start : start+n])
+	for _, o := range opts {
+		if sub == o {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *metaphone) vowel(i int) bool {
+	switch m.at(i) {
+	case 'A', 'E', 'I', 'O', 'U', 'Y':
+		return true
+	}
+	return false
+}
+
+// add appends main to both codes, or main and alt[0] to primary and alternate.
+func (m *metaphone) add(main string, alt ...string) {
+	m.primary.WriteString(main)
+	if len(alt) > 0 {
+		m.alternate.WriteString(alt[0])
+	} else {
+		m.alternate.WriteString(main)
+	}
+}
+
+// step advances past a letter, skipping a doubled one.
+func (m *metaphone) step(cur int, double rune) int {
+	if m.at(cur+1) == double {
+		return cur + 2
+	}
+	return cur + 1
+}
+
+func (m *metaphone) run() {
+	if m.length == 0 {
+		return
+	}
+	cur := 0
+	// skip silent letters at the start
+	if m.is(0, 2, "GN", "KN", "PN", "WR", "PS") {
+		cur++
+	}
+	// initial 'X' is pronounced 'Z', e.g. 'Xavier'
+	if m.at(0) == 'X' {
+		m.add("S")
+		cur++
+	}
+	for (m.primary.Len() < 4 || m.alternate.Len() < 4) && cur < m.length {
+		switch c := m.at(cur); c {
+		case 'A', 'E', 'I', 'O', 'U', 'Y':
+			// all initial vowels map to 'A'
+			if cur == 0 {
+				m.add("A")
+			}
+			cur++
+		case 'B':
+			m.add("P")
+			cur = m.step(cur, 'B')
+		case 'Ç':
+			m.add("S")
+			cur++
+		case 'C':
+			cur = m.c(cur)
+		case 'D':
+			switch {
+			case m.is(cur, 2, "DG") && m.is(cur+2, 1, "I", "E", "Y"):
+				m.add("J") // 'edge'
+				cur += 3
+			case m.is(cur, 2, "DG"):
+				m.add("TK") // 'edgar'
+				cur += 2
+			case m.is(cur, 2, "DT", "DD"):
+				m.add("T")
+				cur += 2
+			default:
+				m.add("T")
+				cur++
+			}
+		case 'F':
+			m.add("F")
+			cur = m.step(cur, 'F')
+		case 'G':
+			cur = m.g(cur)
+		case 'H':
+			// keep only when first or between vowels
+			if (cur == 0 || m.vowel(cur-1)) && m.vowel(cur+1) {
+				m.add("H")
+				cur += 2
+			} else {
+				cur++
+			}
+		case 'J':
+			cur = m.j(cur)
+		case 'K':
+			m.add("K")
+			cur = m.step(cur, 'K')
+		case 'L':
+			if m.at(cur+1) == 'L' {
+				// spanish, e.g. 'cabrillo', 'gallegos'
+				if (cur == m.length-3 && m.is(cur-1, 4, "ILLO", "ILLA", "ALLE")) ||
+					((m.is(m.last-1, 2, "AS", "OS") || m.is(m.last, 1, "A", "O")) && m.is(cur-1, 4, "ALLE")) {
+					m.add("L", "")
+					cur += 2
+					continue
+				}
+				cur += 2
+			} else {
+				cur++
+			}
+			m.add("L")
+		case 'M':
+			if (m.is(cur-1, 3, "UMB") && (cur+1 == m.last || m.is(cur+2, 2, "ER"))) || m.at(cur+1) == 'M' {
+				cur += 2
+			} else {
+				cur++
+			}
+			m.add("M")
+		case 'N':
+			m.add("N")
+			cur = m.step(cur, 'N')
+		case 'Ñ':
+			m.add("N")
+			cur++
+		case 'P':
+			if m.at(cur+1) == 'H' {
+				m.add("F")
+				cur += 2
+				continue
+			}
+			// 'campbell', 'raspberry'
+			if m.is(cur+1, 1, "P", "B") {
+				cur += 2
+			} else {
+				cur++
+			}
+			m.add("P")
+		case 'Q':
+			m.add("K")
+			cur = m.step(cur, 'Q')
+		case 'R':
+			// french, e.g. 'rogier', but not 'hochmeier'
+			if cur == m.last && !m.slavoGermanic && m.is(cur-2, 2, "IE") && !m.is(cur-4, 2, "ME", "MA") {
+				m.add("", "R")
+			} else {
+				m.add("R")
+			}
+			cur = m.step(cur, 'R')
+		case 'S':
+			cur = m.s(cur)
+		case 'T':
+			switch {
+			case m.is(cur, 4, "TION"), m.is(cur, 3, "TIA", "TCH"):
+				m.add("X")
+				cur += 3
+			case m.is(cur, 2, "TH"), m.is(cur, 3, "TTH"):
+				// 'thomas', 'thames' or germanic
+				if m.is(cur+2, 2, "OM", "AM") || m.is(0, 4, "VAN ", "VON ") || m.is(0, 3, "SCH") {
+					m.add("T")
+				} else {
+					m.add("0", "T")
+				}
+				cur += 2
+			default:
+				if m.is(cur+1, 1, "T", "D") {
+					cur += 2
+				} else {
+					cur++
+				}
+				m.add("T")
+			}
+		case 'V':
+			m.add("F")
+			cur = m.step(cur, 'V')
+		case 'W':
+			cur = m.wCase(cur)
+		case 'X':
+			// french, e.g. 'breaux'
+			if !(cur == m.last && (m.is(cur-3, 3, "IAU", "EAU") || m.is(cur-2, 2, "AU", "OU"))) {
+				m.add("KS")
+			}
+			if m.is(cur+1, 1, "C", "X") {
+				cur += 2
+			} else {
+				cur++
+			}
+		case 'Z':
+			// chinese pinyin, e.g. 'zhao'
+			if m.at(cur+1) == 'H' {
+				m.add("J")
+				cur += 2
+				continue
+			}
+			if m.is(cur+1, 2, "ZO", "ZI", "ZA") || (m.slavoGermanic && cur > 0 && m.at(cur-1) != 'T') {
+				m.add("S", "TS")
+			} else {
+				m.add("S")
+			}
+			cur = m.step(cur, 'Z')
+		default:
+			cur++
+		}
+	}
+}
+
+func (m *metaphone) c(cur int) int {
+	// various germanic
+	if cur > 1 && !m.vowel(cur-2) && m.is(cur-1, 3, "ACH") && m.at(cur+2) != 'I' &&
+		(m.at(cur+2) != 'E' || m.is(cur-2, 6, "BACHER", "MACHER")) {
+		m.add("K")
+		return cur + 2
+	}
+	// 'caesar'
+	if cur == 0 && m.is(cur, 6, "CAESAR") {
+		m.add("S")
+		return cur + 2
+	}
+	// italian 'chianti'
+	if m.is(cur, 4, "CHIA") {
+		m.add("K")
+		return cur + 2
+	}
+	if m.is(cur, 2, "CH") {
+		// 'michael'
+		if cur > 0 && m.is(cur, 4, "CHAE") {
+			m.add("K", "X")
+			return cur + 2
+		}
+		// greek roots, e.g. 'chemistry', 'chorus'
+		if cur == 0 && (m.is(cur+1, 5, "HARAC", "HARIS") || m.is(cur+1, 3, "HOR", "HYM", "HIA", "HEM")) && !m.is(0, 5, "CHORE") {
+			m.add("K")
+			return cur + 2
+		}
+		// germanic, greek, or otherwise 'ch' for 'kh' sound
+		if m.is(0, 4, "VAN ", "VON ") || m.is(0, 3, "SCH") ||
+			m.is(cur-2, 6, "ORCHES", "ARCHIT", "ORCHID") ||
+			m.is(cur+2, 1, "T", "S") ||
+			((m.is(cur-1, 1, "A", "O", "U", "E") || cur == 0) &&
+				m.is(cur+2, 1, "L", "R", "N", "M", "B", "H", "F", "V", "W", " ")) {
+			m.add("K")
+		} else if cur > 0 {
+			if m.is(0, 2, "MC") {
+				m.add("K")
+			} else {
+				m.add("X", "K")
+			}
+		} else {
+			m.add("X")
+		}
+		return cur + 2
+	}
+	// 'czerny'
+	if m.is(cur, 2, "CZ") && !m.is(cur-2, 4, "WICZ") {
+		m.add("S", "X")
+		return cur + 2
+	}
+	// 'focaccia'
+	if m.is(cur+1, 3, "CIA") {
+		m.add("X")
+		return cur + 3
+	}
+	// double 'C', but not 'McClellan'
+	if m.is(cur, 2, "CC") && !(cur == 1 && m.at(0) == 'M') {
+		// 'bellocchio' but not 'bacchus'
+		if m.is(cur+2, 1, "I", "E", "H") && !m.is(cur+2, 2, "HU") {
+			// 'accident', 'accede', 'succeed'
+			if (cur == 1 && m.at(cur-1) == 'A') || m.is(cur-1, 5, "UCCEE", "UCCES") {
+				m.add("KS")
+			} else {
+				m.add("X") // 'bacci', 'bertucci'
+			}
+			return cur + 3
+		}
+		m.add("K") // Pierce's rule
+		return cur + 2
+	}
+	if m.is(cur, 2, "CK", "CG", "CQ") {
+		m.add("K")
+		return cur + 2
+	}
+	if m.is(cur, 2, "CI", "CE", "CY") {
+		// italian vs. english
+		if m.is(cur, 3, "CIO", "CIE", "CIA") {
+			m.add("S", "X")
+		} else {
+			m.add("S")
+		}
+		return cur + 2
+	}
+	m.add("K")
+	// 'mac caffrey', 'mac gregor'
+	switch {
+	case m.is(cur+1, 2, " C", " Q", " G"):
+		return cur + 3
+	case m.is(cur+1, 1, "C", "K", "Q") && !m.is(cur+1, 2, "CE", "CI"):
+		return cur + 2
+	}
+	return cur + 1
+}
+
+func (m *metaphone) g(cur int) int {
+	if m.at(cur+1) == 'H' {
+		if cur > 0 && !m.vowel(cur-1) {
+			m.add("K")
+			return cur + 2
+		}
+		// 'ghislane', 'ghiradelli'
+		if cur == 0 {
+			if m.at(cur+2) == 'I' {
+				m.add("J")
+			} else {
+				m.add("K")
+			}
+			return cur + 2
+		}
+		// Parker's rule, e.g. 'hugh', 'bough', 'broughton'
+		if (cur > 1 && m.is(cur-2, 1, "B", "H", "D")) ||
+			(cur > 2 && m.is(cur-3, 1, "B", "H", "D")) ||
+			(cur > 3 && m.is(cur-4, 1, "B", "H")) {
+			return cur + 2
+		}
+		// 'laugh', 'mclaughlin', 'cough', 'rough', 'tough'
+		if cur > 2 && m.at(cur-1) == 'U' && m.is(cur-3, 1, "C", "G", "L", "R", "T") {
+			m.add("F")
+		} else if cur > 0 && m.at(cur-1) != 'I' {
+			m.add("K")
+		}
+		return cur + 2
+	}
+	if m.at(cur+1) == 'N' {
+		if cur == 1 && m.vowel(0) && !m.slavoGermanic {
+			m.add("KN", "N")
+		} else if !m.is(cur+2, 2, "EY") && m.at(cur+1) != 'Y' && !m.slavoGermanic {
+			// not 'cagney'
+			m.add("N", "KN")
+		} else {
+			m.add("KN")
+		}
+		return cur + 2
+	}
+	// 'tagliaro'
+	if m.is(cur+1, 2, "LI") && !m.slavoGermanic {
+		m.add("KL", "L")
+		return cur + 2
+	}
+	// -ges-, -gep-, -gel-, -gie- at the start
+	if cur == 0 && (m.at(cur+1) == 'Y' || m.is(cur+1, 2, "ES", "EP", "EB", "EL", "EY", "IB", "IL", "IN", "IE", "EI", "ER")) {
+		m.add("K", "J")
+		return cur + 2
+	}
+	// -ger-, -gy-
+	if (m.is(cur+1, 2, "ER") || m.at(cur+1) == 'Y') && !m.is(0, 6, "DANGER", "RANGER", "MANGER") &&
+		!m.is(cur-1, 1, "E", "I") && !m.is(cur-1, 3, "RGY", "OGY") {
+		m.add("K", "J")
+		return cur + 2
+	}
+	// italian, e.g. 'biaggi'
+	if m.is(cur+1, 1, "E", "I", "Y") || m.is(cur-1, 4, "AGGI", "OGGI") {
+		switch {
+		case m.is(0, 4, "VAN ", "VON ") || m.is(0, 3, "SCH") || m.is(cur+1, 2, "ET"):
+			m.add("K") // obvious germanic
+		case m.is(cur+1, 4, "IER "):
+			m.add("J") // always soft with a french ending
+		default:
+			m.add("J", "K")
+		}
+		return cur + 2
+	}
+	m.add("K")
+	return m.step(cur, 'G')
+}
+
+func (m *metaphone) j(cur int) int {
+	// obvious spanish, 'jose', 'san jacinto'
+	if m.is(cur, 4, "JOSE") || m.is(0, 4, "SAN ") {
+		if (cur == 0 && m.at(cur+4) == ' ') || m.is(0, 4, "SAN ") {
+			m.add("H")
+		} else {
+			m.add("J", "H")
+		}
+		return cur + 1
+	}
+	switch {
+	case cur == 0:
+		m.add("J", "A") // 'Yankelovich' / 'Jankelowicz'
+	case m.vowel(cur-1) && !m.slavoGermanic && (m.at(cur+1) == 'A' || m.at(cur+1) == 'O'):
+		m.add("J", "H") // spanish, e.g. 'bajador'
+	case cur == m.last:
+		m.add("J", "")
+	case !m.is(cur+1, 1, "L", "T", "K", "S", "N", "M", "B", "Z") && !m.is(cur-1, 1, "S", "K", "L"):
+		m.add("J")
+	}
+	return m.step(cur, 'J')
+}
+
+func (m *metaphone) s(cur int) int {
+	// 'island', 'isle', 'carlisle', 'carlysle'
+	if m.is(cur-1, 3, "ISL", "YSL") {
+		return cur + 1
+	}
+	// 'sugar-'
+	if cur == 0 && m.is(cur, 5, "SUGAR") {
+		m.add("X", "S")
+		return cur + 1
+	}
+	if m.is(cur, 2, "SH") {
+		// germanic
+		if m.is(cur+1, 4, "HEIM", "HOEK", "HOLM", "HOLZ") {
+			m.add("S")
+		} else {
+			m.add("X")
+		}
+		return cur + 2
+	}
+	// italian and armenian
+	if m.is(cur, 3, "SIO", "SIA") || m.is(cur, 4, "SIAN") {
+		if m.slavoGermanic {
+			m.add("S")
+		} else {
+			m.add("S", "X")
+		}
+		return cur + 3
+	}
+	// german and anglicisations, e.g. 'smith' ~ 'schmidt', 'snider' ~ 'schneider';
+	// also -sz- in slavic languages
+	if (cur == 0 && m.is(cur+1, 1, "M", "N", "L", "W")) || m.is(cur+1, 1, "Z") {
+		m.add("S", "X")
+		return m.step(cur, 'Z')
+	}
+	if m.is(cur, 2, "SC") {
+		// Schlesinger's rule
+		if m.at(cur+2) == 'H' {
+			// dutch origin, e.g. 'school', 'schooner'
+			if m.is(cur+3, 2, "OO", "ER", "EN", "UY", "ED", "EM") {
+				// 'schermerhorn', 'schenker'
+				if m.is(cur+3, 2, "ER", "EN") {
+					m.add("X", "SK")
+				} else {
+					m.add("SK")
+				}
+				return cur + 3
+			}
+			if cur == 0 && !m.vowel(3) && m.at(3) != 'W' {
+				m.add("X", "S")
+			} else {
+				m.add("X")
+			}
+			return cur + 3
+		}
+		if m.is(cur+2, 1, "I", "E", "Y") {
+			m.add("S")
+		} else {
+			m.add("SK")
+		}
+		return cur + 3
+	}
+	// french, e.g. 'resnais', 'artois'
+	if cur == m.last && m.is(cur-2, 2, "AI", "OI") {
+		m.add("", "S")
+	} else {
+		m.add("S")
+	}
+	if m.is(cur+1, 1, "S", "Z") {
+		return cur + 2
+	}
+	return cur + 1
+}
+
+func (m *metaphone) wCase(cur int) int {
+	if m.is(cur, 2, "WR") {
+		m.add("R")
+		return cur + 2
+	}
+	if cur == 0 && (m.vowel(cur+1) || m.is(cur, 2, "WH")) {
+		// 'Wasserman' ~ 'Vasserman', 'Uomo' ~ 'Womo'
+		if m.vowel(cur + 1) {
+			m.add("A", "F")
+		} else {
+			m.add("A")
+		}
+	}
+	// 'Arnow' ~ 'Arnoff'
+	if (cur == m.last && m.vowel(cur-1)) || m.is(cur-1, 5, "EWSKI", "EWSKY", "OWSKI", "OWSKY") || m.is(0, 3, "SCH") {
+		m.add("", "F")
+		return cur + 1
+	}
+	// polish, e.g. 'filipowicz'
+	if m.is(cur, 4, "WICZ", "WITZ") {
+		m.add("TS", "FX")
+		return cur + 4
+	}
+	return cur + 1
+}
@@ -0,0 +1,257 @@
+package csvops
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/utils"
+)
+
+// Replacement dictionaries: FindAndReplace can take its rules from a mapping table
+// (one from -> to pair per row) maintained in a spreadsheet instead of hand-built
+// ReplaceRules.
+
+// ReplaceMapping expands into one rule per distinct (to, flags), whose targets are
+// the from values of its rows. Mapping rules run after the request's own rules.
+type ReplaceMapping struct {
+	Table types.TableData `json:"table"`
+	From  string          `json:"from"` // header name or numeric index string; "" => "0"
+	To    string          `json:"to"`   // "" => "1"
+	Mode  ReplaceMode     `json:"mode,omitempty"`
+
+	// Optional flag columns (true/yes/y/1 or false/no/n/0); blank cells fall back to
+	// the options.
+	CaseInsensitive string `json:"case_insensitive,omitempty"`
+	WholeCell       string `json:"whole_cell,omitempty"`
+
+	// Strict fails the request on conflicts, cycles and chains. Otherwise the first
+	// mapping of a conflicting source wins, the mappings of a cycle are dropped and
+	// chains are kept (the rule order decides how far a value travels).
+	Strict bool `json:"strict"`
+}
+
+const (
+	MappingConflict = "conflict" // one source mapped to different targets
+	MappingCycle    = "cycle"    // A -> B, B -> A: the result would depend on rule order
+	MappingChain    = "chain"    // A -> B, B -> C: A becomes B or C depending on rule order
+)
+
+// MappingIssue reports a conflict, cycle or chain found in a mapping table.
+type MappingIssue struct {
+	Kind    string   `json:"kind"`    // conflict | cycle | chain
+	Sources []string `json:"sources"` // conflict: the source; cycle, chain: its sources in order
+	Targets []string `json:"targets"` // conflict: the targets in row order; cycle, chain: each source's target
+	Rows    []int    `json:"rows"`    // 1-based data rows of the mapping table involved
+}
+
+// mappingRow is one usable row of a mapping table.
+type mappingRow struct {
+	from, to  string
+	ci, wc    *bool
+	key, next string // normalized from and to values
+	row       int
+	dropped   bool
+}
+
+// parseFlagCell reads an optional boolean flag cell.
+func parseFlagCell(cell string) (*bool, error) {
+	switch strings.ToLower(strings.TrimSpace(cell)) {
+	case "":
+		return nil, nil
+	case "true", "yes", "y", "1":
+		v := true
+		return &v, nil
+	case "false", "no", "n", "0":
+		v := false
+		return &v, nil
+	}
+	return nil, fmt.Errorf("'%s' is not a boolean", cell)
+}
+
+// mappingRules expands m into rules and reports its conflicts, cycles and chains.
+func mappingRules(m *ReplaceMapping, opts FindReplaceOptions) ([]ReplaceRule, []MappingIssue, error) {
+	col := func(name, def string) string {
+		if strings.TrimSpace(name) == "" {
+			return def
+		}
+		return name
+	}
+	fromIdx, err := utils.ResolveKeyIndex(m.Table, col(m.From, "0"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("mapping from column: %w", err)
+	}
+	toIdx, err := utils.ResolveKeyIndex(m.Table, col(m.To, "1"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("mapping to column: %w", err)
+	}
+	ciIdx, wcIdx := -1, -1
+	if m.CaseInsensitive != "" {
+		if ciIdx, err = utils.ResolveKeyIndex(m.Table, m.CaseInsensitive); err != nil {
+			return nil, nil, fmt.Errorf("mapping case_insensitive column: %w", err)
+		}
+	}
+	if m.WholeCell != "" {
+		if wcIdx, err = utils.ResolveKeyIndex(m.Table, m.WholeCell); err != nil {
+			return nil, nil, fmt.Errorf("mapping whole_cell column: %w", err)
+		}
+	}
+
+	var rows []*mappingRow
+	bySource := map[string]*mappingRow{}
+	conflicts := map[string]*MappingIssue{}
+	var issues []*MappingIssue
+	for i, r := range m.Table.Rows {
+		mr := &mappingRow{from: cellAt(r, fromIdx), to: cellAt(r, toIdx), row: i + 1}
+		if mr.from == "" {
+			continue
+		}
+		if ciIdx >= 0 {
+			if mr.ci, err = parseFlagCell(cellAt(r, ciIdx)); err != nil {
+				return nil, nil, fmt.Errorf("mapping row %d: case_insensitive: %w", mr.row, err)
+			}
+		}
+		if wcIdx >= 0 {
+			if mr.wc, err = parseFlagCell(cellAt(r, wcIdx)); err != nil {
+				return nil, nil, fmt.Errorf("mapping row %d: whole_cell: %w", mr.row, err)
+			}
+		}
+		ci := opts.CaseInsensitive
+		if mr.ci != nil {
+			ci = *mr.ci
+		}
+		normalize := func(s string) string {
+			if opts.TrimSpaces {
+				s = strings.TrimSpace(s)
+			}
+			if ci {
+				s = strings.ToLower(s)
+			}
+			return s
+		}
+		mr.key, mr.next = normalize(mr.from), normalize(mr.to)
+
+		first, seen := bySource[mr.key]
+		if !seen {
+			bySource[mr.key] = mr
+			rows = append(rows, mr)
+			continue
+		}
+		if first.next == mr.next {
+			continue // repeated mapping, possibly spelled differently
+		}
+		// the first mapping of a source wins
+		c := conflicts[mr.key]
+		if c == nil {
+			c = &MappingIssue{Kind: MappingConflict, Sources: []string{first.from}, Targets: []string{first.to}, Rows: []int{first.row}}
+			conflicts[mr.key] = c
+			issues = append(issues, c)
+		}
+		c.Targets = append(c.Targets, mr.to)
+		c.Rows = append(c.Rows, mr.row)
+	}
+
+	// every source has one target now, so following targets from a source either
+	// ends or runs into a cycle; mappings onto their own source (case fixes) are not
+	// cycles
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := map[string]int{}
+	for _, start := range rows {
+		var path []*mappingRow
+		for mr := start; mr != nil && state[mr.key] == unvisited; {
+			state[mr.key] = onPath
+			path = append(path, mr)
+			if mr.next == mr.key {
+				break
+			}
+			next := bySource[mr.next]
+			if next != nil && state[next.key] == onPath {
+				// the cycle is the tail of the path from next
+				c := &MappingIssue{Kind: MappingCycle}
+				inCycle := false
+				for _, p := range path {
+					if p == next {
+						inCycle = true
+					}
+					if inCycle {
+						p.dropped = true
+						c.Sources = append(c.Sources, p.from)
+						c.Targets = append(c.Targets, p.to)
+						c.Rows = append(c.Rows, p.row)
+					}
+				}
+				issues = append(issues, c)
+				break
+			}
+			mr = next
+		}
+		for _, p := range path {
+			state[p.key] = done
+		}
+	}
+
+	// a target that is itself remapped forms a chain; mappings onto their own
+	// source end a chain harmlessly
+	for _, mr := range rows {
+		if mr.dropped || mr.next == mr.key {
+			continue
+		}
+		if next := bySource[mr.next]; next != nil && !next.dropped && next.next != next.key {
+			issues = append(issues, &MappingIssue{
+				Kind:    MappingChain,
+				Sources: []string{mr.from, next.from},
+				Targets: []string{mr.to, next.to},
+				Rows:    []int{mr.row, next.row},
+			})
+		}
+	}
+
+	// group the remaining mappings into rules by target and flags, in row order
+	var rules []ReplaceRule
+	byRule := map[string]int{}
+	flagKey := func(b *bool) string {
+		if b == nil {
+			return "-"
+		}
+		return fmt.Sprint(*b)
+	}
+	for _, mr := range rows {
+		if mr.dropped {
+			continue
+		}
+		k := mr.to + utils.KeySeparator + flagKey(mr.ci) + flagKey(mr.wc)
+		i, ok := byRule[k]
+		if !ok {
+			i = len(rules)
+			byRule[k] = i
+			rules = append(rules, ReplaceRule{Replacement: mr.to, Mode: m.Mode, CaseInsensitive: mr.ci, WholeCell: mr.wc})
+		}
+		rules[i].Targets = append(rules[i].Targets, mr.from)
+	}
+
+	out := make([]MappingIssue, 0, len(issues))
+	for _, is := range issues {
+		out = append(out, *is)
+	}
+	return rules, out, nil
+}
+
+// expandMapping appends the rules of req.Mapping to req.Rules.
+func expandMapping(req *FindReplaceRequest) ([]MappingIssue, error) {
+	if req.Mapping == nil {
+		return nil, nil
+	}
+	rules, issues, err := mappingRules(req.Mapping, req.Options)
+	if err != nil {
+		return nil, err
+	}
+	if req.Mapping.Strict && len(issues) > 0 {
+		return issues, fmt.Errorf("mapping has %d conflicts, cycles or chains", len(issues))
+	}
+	req.Rules = append(append([]ReplaceRule(nil), req.Rules...), rules...)
+	return issues, nil
+}
@@ -0,0 +1,109 @@
+package csvops
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+)
+
+func TestMappingRules(t *testing.T) {
+	tests := []struct {
+		name       string
+		rows       [][]string
+		opts       FindReplaceOptions
+		wantRules  map[string][]string // replacement -> targets
+		wantIssues []MappingIssue
+	}{
+		{
+			name:      "plain",
+			rows:      [][]string{{"U.S.", "USA"}, {"United States", "USA"}, {"UK", "United Kingdom"}},
+			wantRules: map[string][]string{"USA": {"U.S.", "United States"}, "United Kingdom": {"UK"}},
+		},
+		{
+			name:      "repeated mapping",
+			rows:      [][]string{{"a", "X"}, {"a", "X"}},
+			wantRules: map[string][]string{"X": {"a"}},
+		},
+		{
+			name:      "conflict",
+			rows:      [][]string{{"a", "X"}, {"b", "Y"}, {"a", "Z"}, {"a", "W"}},
+			wantRules: map[string][]string{"X": {"a"}, "Y": {"b"}},
+			wantIssues: []MappingIssue{
+				{Kind: MappingConflict, Sources: []string{"a"}, Targets: []string{"X", "Z", "W"}, Rows: []int{1, 3, 4}},
+			},
+		},
+		{
+			name:      "equivalent targets are no conflict",
+			rows:      [][]string{{"a", "X"}, {"A ", " x"}},
+			opts:      FindReplaceOptions{CaseInsensitive: true, TrimSpaces: true},
+			wantRules: map[string][]string{"X": {"a"}},
+		},
+		{
+			name:      "case differences conflict when case-sensitive",
+			rows:      [][]string{{"a", "X"}, {"a", "x"}},
+			wantRules: map[string][]string{"X": {"a"}},
+			wantIssues: []MappingIssue{
+				{Kind: MappingConflict, Sources: []string{"a"}, Targets: []string{"X", "x"}, Rows: []int{1, 2}},
+			},
+		},
+		{
+			name:      "cycle",
+			rows:      [][]string{{"a", "b"}, {"b", "c"}, {"c", "a"}, {"d", "e"}},
+			wantRules: map[string][]string{"e": {"d"}},
+			wantIssues: []MappingIssue{
+				{Kind: MappingCycle, Sources: []string{"a", "b", "c"}, Targets: []string{"b", "c", "a"}, Rows: []int{1, 2, 3}},
+			},
+		},
+		{
+			name:      "self mappings",
+			rows:      [][]string{{"usa", "USA"}, {"U.S.", "usa"}},
+			opts:      FindReplaceOptions{CaseInsensitive: true},
+			wantRules: map[string][]string{"USA": {"usa"}, "usa": {"U.S."}},
+		},
+		{
+			name:      "chain",
+			rows:      [][]string{{"a", "b"}, {"b", "c"}},
+			wantRules: map[string][]string{"b": {"a"}, "c": {"b"}},
+			wantIssues: []MappingIssue{
+				{Kind: MappingChain, Sources: []string{"a", "b"}, Targets: []string{"b", "c"}, Rows: []int{1, 2}},
+			},
+		},
+		{
+			name:      "chain into a cycle",
+			rows:      [][]string{{"x", "a"}, {"a", "b"}, {"b", "a"}},
+			wantRules: map[string][]string{"a": {"x"}},
+			wantIssues: []MappingIssue{
+				{Kind: MappingCycle, Sources: []string{"a", "b"}, Targets: []string{"b", "a"}, Rows: []int{2, 3}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &ReplaceMapping{Table: types.TableData{Rows: tt.rows}}
+			rules, issues, err := mappingRules(m, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string][]string{}
+			for _, r := range rules {
+				got[r.Replacement] = append(got[r.Replacement], r.Targets...)
+			}
+			if !reflect.DeepEqual(got, tt.wantRules) {
+				t.Errorf("rules = %v, want %v", got, tt.wantRules)
+			}
+			if len(issues) == 0 {
+				issues = nil
+			}
+			if !reflect.DeepEqual(issues, tt.wantIssues) {
+				t.Errorf("issues = %+v, want %+v", issues, tt.wantIssues)
+			}
+
+			m.Strict = true
+			req := FindReplaceRequest{Options: tt.opts, Mapping: m}
+			if _, err := expandMapping(&req); (err != nil) != (len(tt.wantIssues) > 0) {
+				t.Errorf("strict expandMapping error = %v, want one only with issues", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package csvops
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/utils"
+)
+
+// Set operations compare each list against the master the way CrossRefMulti does,
+// but return the rows on either side of the comparison instead of only the matches.
+
+type SetOp string
+
+const (
+	SetOpDifference          SetOp = "set_difference"
+	SetOpIntersection        SetOp = "set_intersection"
+	SetOpUnion               SetOp = "set_union"
+	SetOpSymmetricDifference SetOp = "symmetric_difference"
+)
+
+// SetSide selects whose rows difference and intersection return.
+type SetSide string
+
+const (
+	SideList   SetSide = "list"   // rows of the list (default)
+	SideMaster SetSide = "master" // rows of the master
+)
+
+// SourceColumn is the first column of union and symmetric difference results;
+// it holds "master" or the list name the row came from.
+const SourceColumn = "_source"
+
+// SetOpRequest has the CrossRefMultiRequest shape: a master and named lists.
+type SetOpRequest struct {
+	Operation string              `json:"operation"`
+	Options   SetOpOptions        `json:"options"`
+	Datasets  types.MultiDatasets `json:"datasets"`
+}
+
+// SetOpOptions extends the crossref options (match_method, master_key(s), list_key(s),
+// trim_spaces). With whole_row the keys are ignored and entire rows are compared;
+// when both tables have headers, list columns are lined up with the master's by name.
+type SetOpOptions struct {
+	CrossRefMultiOptions
+	WholeRow bool    `json:"whole_row"`
+	Side     SetSide `json:"side,omitempty"` // difference/intersection: list | master; "" => list
+}
+
+// PerSetResult holds the counts and result rows for one list.
+type PerSetResult struct {
+	Name       string          `json:"name"`
+	Processed  int             `json:"processed"`   // list rows read
+	Matched    int             `json:"matched"`     // list rows whose key is in the master
+	Missing    int             `json:"missing"`     // list rows whose key is not in the master
+	MasterOnly int             `json:"master_only"` // master rows whose key is not in the list
+	Result     types.TableData `json:"result"`
+	Error      *string         `json:"error"`
+}
+
+type SetOpResponse struct {
+	Operation string         `json:"operation"`
+	Summary   map[string]int `json:"summary"`
+	PerList   []PerSetResult `json:"per_list"`
+	Error     *string        `json:"error"`
+}
+
+// SetDifference returns, per list, the list rows whose key is not in the master
+// (side "master": the master rows whose key is not in the list).
+func SetDifference(req SetOpRequest) (SetOpResponse, error) {
+	return runSetOp(SetOpDifference, req)
+}
+
+// SetIntersection returns, per list, the list rows whose key is also in the master
+// (side "master": the master rows whose key is also in the list).
+func SetIntersection(req SetOpRequest) (SetOpResponse, error) {
+	return runSetOp(SetOpIntersection, req)
+}
+
+// SetUnion returns, per list, every master row followed by the list rows whose key
+// is not in the master, prefixed with a _source column.
+func SetUnion(req SetOpRequest) (SetOpResponse, error) {
+	return runSetOp(SetOpUnion, req)
+}
+
+// SymmetricDifference returns, per list, the master rows whose key is not in the
+// list followed by the list rows whose key is not in the master, prefixed with a
+// _source column.
+func SymmetricDifference(req SetOpRequest) (SetOpResponse, error) {
+	return runSetOp(SetOpSymmetricDifference, req)
+}
+
+// setKeyFunc builds the comparison key of a row from the resolved key columns.
+// cols lines the row up with the master's columns for whole-row comparisons
+// (nil keeps the row as it is).
+func setKeyFunc(opts SetOpOptions, normalize func(string) string, idxs []int, cols []int) func([]string) string {
+	if opts.WholeRow {
+		return func(row []string) string {
+			row = alignRow(row, cols)
+			parts := make([]string, len(row))
+			for i, c := range row {
+				parts[i] = normalize(c)
+			}
+			// trailing empty cells do not make rows different
+			for len(parts) > 0 && parts[len(parts)-1] == "" {
+				parts = parts[:len(parts)-1]
+			}
+			return strings.Join(parts, utils.KeySeparator)
+		}
+	}
+	return func(row []string) string {
+		return utils.CompositeKey(row, idxs, normalize)
+	}
+}
+
+// alignColumns maps every master column to the list column with the same header
+// (-1 when the list has none). It returns nil when either table has no header,
+// in which case columns are matched by position.
+func alignColumns(master, list types.TableData) []int {
+	if !master.HasHeader || !list.HasHeader {
+		return nil
+	}
+	pos := make(map[string]int, len(list.Header))
+	for i, h := range list.Header {
+		k := strings.ToLower(strings.TrimSpace(h))
+		if _, dup := pos[k]; !dup {
+			pos[k] = i
+		}
+	}
+	cols := make([]int, len(master.Header))
+	for i, h := range master.Header {
+		j, ok := pos[strings.ToLower(strings.TrimSpace(h))]
+		if !ok {
+			j = -1
+		}
+		cols[i] = j
+	}
+	return cols
+}
+
+// alignRow reorders row according to cols; nil cols returns row unchanged.
+func alignRow(row []string, cols []int) []string {
+	if cols == nil {
+		return row
+	}
+	out := make([]string, len(cols))
+	for i, j := range cols {
+		if j >= 0 {
+			out[i] = cellAt(row, j)
+		}
+	}
+	return out
+}
+
+// keySet returns the set of keys of rows; rows too short to hold every key
+// column in idxs are left out.
+func keySet(rows [][]string, idxs []int, key func([]string) string) map[string]struct{} {
+	set := make(map[string]struct{}, len(rows))
+	for _, r := range rows {
+		if hasKeyCells(r, idxs) {
+			set[key(r)] = struct{}{}
+		}
+	}
+	return set
+}
+
+// inKeySet reports whether row has every key column in idxs and its key is in set.
+func inKeySet(set map[string]struct{}, row []string, idxs []int, key func([]string) string) bool {
+	if !hasKeyCells(row, idxs) {
+		return false
+	}
+	_, ok := set[key(row)]
+	return ok
+}
+
+// runSetOp validates the request and applies op to every list.
+func runSetOp(op SetOp, req SetOpRequest) (SetOpResponse, error) {
+	var res SetOpResponse
+	res.Operation = req.Operation
+	start := time.Now()
+
+	opts := req.Options
+	if !opts.WholeRow && len(masterKeysFor(opts.CrossRefMultiOptions)) == 0 {
+		msg := "master_key required (or set whole_row)"
+		res.Error = &msg
+		return res, errors.New(msg)
+	}
+	if m := opts.MatchMethod; m != "" && m != MatchExact && m != MatchCaseInsensitive {
+		msg := fmt.Sprintf("match method '%s' is not supported by set operations (use exact | case_insensitive)", opts.MatchMethod)
+		res.Error = &msg
+		return res, errors.New(msg)
+	}
+	switch opts.Side {
+	case "", SideList, SideMaster:
+	default:
+		msg := fmt.Sprintf("invalid side '%s' (expected list | master)", opts.Side)
+		res.Error = &msg
+		return res, errors.New(msg)
+	}
+	normalize, err := opts.keyNormalizer()
+	if err != nil {
+		msg := err.Error()
+		res.Error = &msg
+		return res, err
+	}
+	master := req.Datasets.Master
+	var masterIdxs []int
+	if !opts.WholeRow {
+		idxs, err := utils.ResolveKeyIndices(master, masterKeysFor(opts.CrossRefMultiOptions))
+		if err != nil {
+			msg := "master key resolution: " + err.Error()
+			res.Error = &msg
+			return res, errors.New(msg)
+		}
+		masterIdxs = idxs
+	}
+	masterKey := setKeyFunc(opts, normalize, masterIdxs, nil)
+	masterSet := keySet(master.Rows, masterIdxs, masterKey)
+
+	totals := map[string]int{}
+	perList := make([]PerSetResult, 0, len(req.Datasets.Lists))
+	for _, named := range req.Datasets.Lists {
+		pl := setOpList(op, opts, normalize, master, masterIdxs, masterKey, masterSet, named)
+		totals["processed_total"] += pl.Processed
+		totals["matched_total"] += pl.Matched
+		totals["missing_total"] += pl.Missing
+		totals["master_only_total"] += pl.MasterOnly
+		totals["result_total"] += len(pl.Result.Rows)
+		perList = append(perList, pl)
+	}
+
+	res.PerList = perList
+	res.Summary = totals
+	res.Summary["master_count"] = len(master.Rows)
+	res.Summary["lists_count"] = len(req.Datasets.Lists)
+	res.Summary["duration_ms"] = int(time.Since(start).Milliseconds())
+	res.Error = nil
+	return res, nil
+}
+
+// setOpList compares one list with the master and builds its result table.
+func setOpList(op SetOp, opts SetOpOptions, normalize func(string) string, master types.TableData, masterIdxs []int, masterKey func([]string) string, masterSet map[string]struct{}, named types.NamedTable) PerSetResult {
+	pl := PerSetResult{Name: named.Name}
+	list := named.Table
+
+	cols := alignColumns(master, list)
+	var listIdxs []int
+	if !opts.WholeRow {
+		idxs, err := resolveListKeys(opts.CrossRefMultiOptions, list, listKeysFor(opts.CrossRefMultiOptions, named.ListKey, named.ListKeys))
+		if err != nil {
+			msg := "list key resolution: " + err.Error()
+			pl.Error = &msg
+			return pl
+		}
+		listIdxs = idxs
+	}
+	listKey := setKeyFunc(opts, normalize, listIdxs, cols)
+	listSet := keySet(list.Rows, listIdxs, listKey)
+
+	// list rows split by membership in the master
+	var listIn, listOut [][]string
+	for _, r := range list.Rows {
+		pl.Processed++
+		if inKeySet(masterSet, r, listIdxs, listKey) {
+			pl.Matched++
+			listIn = append(listIn, r)
+		} else {
+			pl.Missing++
+			listOut = append(listOut, r)
+		}
+	}
+	// master rows split by membership in the list
+	var masterIn, masterOut [][]string
+	for _, r := range master.Rows {
+		if inKeySet(listSet, r, masterIdxs, masterKey) {
+			masterIn = append(masterIn, r)
+		} else {
+			pl.MasterOnly++
+			masterOut = append(masterOut, r)
+		}
+	}
+
+	switch op {
+	case SetOpDifference, SetOpIntersection:
+		rows, from := listOut, list
+		switch {
+		case op == SetOpIntersection && opts.Side == SideMaster:
+			rows, from = masterIn, master
+		case op == SetOpIntersection:
+			rows = listIn
+		case opts.Side == SideMaster:
+			rows, from = masterOut, master
+		}
+		pl.Result = types.TableData{
+			HasHeader: from.HasHeader,
+			Header:    append([]string(nil), from.Header...),
+			Rows:      copyRows(rows),
+		}
+	case SetOpUnion, SetOpSymmetricDifference:
+		masterRows := masterOut
+		if op == SetOpUnion {
+			masterRows = master.Rows
+		}
+		pl.Result = combineSides(master, masterRows, named.Name, listOut, cols)
+	}
+	return pl
+}
+
+// combineSides stacks master rows and list rows under the master header, tagging
+// each with its source. List rows are lined up with the master columns by cols.
+func combineSides(master types.TableData, masterRows [][]string, listName string, listRows [][]string, cols []int) types.TableData {
+	out := types.TableData{HasHeader: master.HasHeader, Rows: [][]string{}}
+	if master.HasHeader {
+		out.Header = append([]string{SourceColumn}, master.Header...)
+	}
+	for _, r := range masterRows {
+		out.Rows = append(out.Rows, append([]string{"master"}, r...))
+	}
+	for _, r := range listRows {
+		out.Rows = append(out.Rows, append([]string{listName}, alignRow(r, cols)...))
+	}
+	return out
+}
+
+// copyRows returns a deep copy of rows (never nil).
+func copyRows(rows [][]string) [][]string {
+	out := make([][]string, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, append([]string(nil), r...))
+	}
+	return out
+}
@@ -0,0 +1,248 @@
+package csvops
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// --- Natural order ("item2" < "item10") ---
+
+// naturalCompare compares two strings treating runs of ASCII digits as numbers.
+// Numbers that only differ in leading zeros ("7" vs "007") fall back to the
+// shorter run first so the order stays total.
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		ad, bd := isDigit(a[0]), isDigit(b[0])
+		switch {
+		case ad && bd:
+			na, ra := digitRun(a)
+			nb, rb := digitRun(b)
+			if c := compareDigits(na, nb); c != 0 {
+				return c
+			}
+			a, b = ra, rb
+		case ad != bd:
+			// digits sort before text, like in byte order
+			if ad {
+				return -1
+			}
+			return 1
+		default:
+			ta, ra := textRun(a)
+			tb, rb := textRun(b)
+			if c := strings.Compare(ta, tb); c != 0 {
+				return c
+			}
+			a, b = ra, rb
+		}
+	}
+	return cmpInt(len(a), len(b))
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func digitRun(s string) (string, string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func textRun(s string) (string, string) {
+	i := 0
+	for i < len(s) && !isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// compareDigits compares two digit runs by value without overflowing on long runs.
+func compareDigits(a, b string) int {
+	ta, tb := strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if c := cmpInt(len(ta), len(tb)); c != 0 {
+		return c
+	}
+	if c := strings.Compare(ta, tb); c != 0 {
+		return c
+	}
+	return cmpInt(len(a), len(b))
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// --- Semantic versions ("1.9.0" < "1.10.0") ---
+
+// version is a parsed dotted version with an optional pre-release ("v1.2.3-rc.1+build").
+// Missing trailing components count as 0, so "1.2" == "1.2.0".
+type version struct {
+	core []uint64
+	pre  []string
+}
+
+// parseVersion parses a version string; build metadata after '+' is ignored.
+func parseVersion(s string) (version, bool) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	var v version
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		if s[i+1:] == "" {
+			return version{}, false
+		}
+		v.pre = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+	if s == "" {
+		return version{}, false
+	}
+	for _, part := range strings.Split(s, ".") {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return version{}, false
+		}
+		v.core = append(v.core, n)
+	}
+	return v, true
+}
+
+// compareVersion orders versions by their numeric components, then by pre-release
+// following semver precedence (a release sorts after its pre-releases).
+func compareVersion(a, b version) int {
+	for i := 0; i < len(a.core) || i < len(b.core); i++ {
+		var x, y uint64
+		if i < len(a.core) {
+			x = a.core[i]
+		}
+		if i < len(b.core) {
+			y = b.core[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(a.pre) == 0 && len(b.pre) == 0:
+		return 0
+	case len(a.pre) == 0:
+		return 1
+	case len(b.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(a.pre) && i < len(b.pre); i++ {
+		if c := comparePreRelease(a.pre[i], b.pre[i]); c != 0 {
+			return c
+		}
+	}
+	return cmpInt(len(a.pre), len(b.pre))
+}
+
+// comparePreRelease compares one pre-release identifier: numeric identifiers
+// compare by value and sort before alphanumeric ones.
+func comparePreRelease(a, b string) int {
+	na, errA := strconv.ParseUint(a, 10, 64)
+	nb, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil:
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+		return 0
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// --- IP addresses ---
+
+// parseIPKey parses an IPv4/IPv6 address or CIDR prefix. IPv4-mapped IPv6
+// addresses are unmapped so they sort together with plain IPv4 addresses.
+// IPv4 sorts before IPv6; a bare address sorts like its host prefix (/32, /128).
+func parseIPKey(s string) (netip.Prefix, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return netip.Prefix{}, false
+	}
+	if addr, err := netip.ParseAddr(s); err == nil {
+		addr = addr.Unmap().WithZone("")
+		return netip.PrefixFrom(addr, addr.BitLen()), true
+	}
+	if p, err := netip.ParsePrefix(s); err == nil {
+		addr := p.Addr()
+		bits := p.Bits()
+		if addr.Is4In6() {
+			addr = addr.Unmap()
+			bits -= 96
+			if bits < 0 {
+				bits = 0
+			}
+		}
+		return netip.PrefixFrom(addr, bits), true
+	}
+	return netip.Prefix{}, false
+}
+
+func compareIP(a, b netip.Prefix) int {
+	if c := a.Addr().Compare(b.Addr()); c != 0 {
+		return c
+	}
+	return cmpInt(a.Bits(), b.Bits())
+}
+
+// --- Locale collation ---
+
+// collator turns cells into binary collation keys for one locale. It is not safe
+// for concurrent use, which is fine because a sort level is used by one goroutine.
+type collator struct {
+	c   *collate.Collator
+	buf collate.Buffer
+}
+
+// newCollator builds a collator for a BCP 47 tag ("de", "fr", "sv", "en-US");
+// an empty tag uses the root (language-neutral Unicode) order.
+func newCollator(locale string, caseInsensitive bool) (*collator, error) {
+	tag := language.Und
+	if strings.TrimSpace(locale) != "" {
+		t, err := language.Parse(strings.TrimSpace(locale))
+		if err != nil {
+			return nil, fmt.Errorf("invalid locale '%s'", locale)
+		}
+		tag = t
+	}
+	var opts []collate.Option
+	if caseInsensitive {
+		opts = append(opts, collate.IgnoreCase)
+	}
+	return &collator{c: collate.New(tag, opts...)}, nil
+}
+
+// key returns the collation key of s; keys compare with strings.Compare.
+func (c *collator) key(s string) string {
+	k := string(c.c.KeyFromString(&c.buf, s))
+	c.buf.Reset()
+	return k
+}
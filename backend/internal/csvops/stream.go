@@ -0,0 +1,97 @@
+package csvops
+
+import (
+	"io"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+)
+
+// StreamTable pairs a named input stream with the writer that receives its result rows.
+// It is the streaming counterpart of types.NamedTable.
+//
+// The *Stream operations share one contract: they take their inputs from StreamTables
+// (or a RowStream) instead of req.Datasets/req.Dataset, write the result header (if
+// any) and rows to the output as they go, and return the usual response with the
+// counts filled in and an empty Result.
+type StreamTable struct {
+	Name     string
+	ListKey  string
+	ListKeys []string
+	Input    types.RowStream
+	Output   types.RowWriter
+
+	// Scores, when set, receives the best fuzzy candidate of every list row
+	// (MatchScoresHeader, then FuzzyMatch.Record rows). Streamed crossref does not
+	// keep fuzzy matches in memory, so without Scores they are dropped.
+	Scores types.RowWriter
+}
+
+// sliceRows adapts in-memory rows to types.RowReader. Each row is returned as a
+// copy so operations may modify it without touching the caller's table.
+type sliceRows struct {
+	rows [][]string
+	pos  int
+}
+
+func (s *sliceRows) Next() ([]string, error) {
+	if s.pos >= len(s.rows) {
+		return nil, io.EOF
+	}
+	row := append([]string(nil), s.rows[s.pos]...)
+	s.pos++
+	return row, nil
+}
+
+// rowCollector is a types.RowWriter that keeps the rows in memory.
+type rowCollector struct {
+	rows [][]string
+}
+
+func newRowCollector() *rowCollector {
+	return &rowCollector{rows: [][]string{}}
+}
+
+func (c *rowCollector) Write(row []string) error {
+	c.rows = append(c.rows, row)
+	return nil
+}
+
+// peekRows yields an already-read first row before the rest of src.
+type peekRows struct {
+	first []string
+	src   types.RowReader
+}
+
+func (p *peekRows) Next() ([]string, error) {
+	if p.first != nil {
+		row := p.first
+		p.first = nil
+		return row, nil
+	}
+	return p.src.Next()
+}
+
+// peekFirst reads the first row of src so column resolution can see the table
+// shape (headerless tables are sized from their first row, as in memory). It
+// returns that shape as a TableData with at most one row plus a reader that
+// still yields every row.
+func peekFirst(src types.RowStream) (types.TableData, types.RowReader, error) {
+	shape := types.TableData{HasHeader: src.HasHeader, Header: src.Header, Rows: [][]string{}}
+	first, err := src.Rows.Next()
+	if err == io.EOF {
+		return shape, src.Rows, nil
+	}
+	if err != nil {
+		return shape, nil, err
+	}
+	shape.Rows = append(shape.Rows, first)
+	return shape, &peekRows{first: first, src: src.Rows}, nil
+}
+
+// writeHeader writes the stream header to dst when the stream has one.
+func writeHeader(src types.RowStream, dst types.RowWriter) error {
+	if !src.HasHeader {
+		return nil
+	}
+	return dst.Write(append([]string(nil), src.Header...))
+}
@@ -0,0 +1,360 @@
+package csvops
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/utils"
+)
+
+// Traverse follows key relationships across the master and lists from a starting
+// value ("link analysis"): device -> user in an asset list, user -> other devices
+// in the same list, user -> hosts in an auth log, and so on. Every value reached
+// becomes a node typed by its column, every relation row linking two nodes an edge.
+
+const (
+	DefaultTraverseDepth    = 2
+	DefaultTraverseMaxNodes = 10000
+)
+
+// Relation links two columns of one table: every row connects its From value to
+// its To value. Values of columns with the same type are the same node, which is
+// how relations in different tables chain.
+type Relation struct {
+	Table    string `json:"table"`               // a list name, or "master" when no list has that name
+	From     string `json:"from"`                // header name or numeric index string
+	To       string `json:"to"`                  // header name or numeric index string
+	FromType string `json:"from_type,omitempty"` // node type of the From values; "" => From, lowercased
+	ToType   string `json:"to_type,omitempty"`   // node type of the To values; "" => To, lowercased
+	Label    string `json:"label,omitempty"`     // edge label; "" => table name
+}
+
+// TraverseStart is the node the traversal starts from.
+type TraverseStart struct {
+	Type  string `json:"type"`  // a node type of some relation, e.g. "device"
+	Value string `json:"value"` // e.g. "pc1"
+}
+
+type TraverseOptions struct {
+	MatchMethod MatchMethod     `json:"match_method"` // exact | case_insensitive
+	TrimSpaces  bool            `json:"trim_spaces"`
+	Normalizers []KeyNormalizer `json:"normalizers,omitempty"`
+	MaxDepth    int             `json:"max_depth"`           // hops from the start; 0 => 2
+	MaxNodes    int             `json:"max_nodes,omitempty"` // 0 => 10000; the graph is truncated beyond it
+	Directed    bool            `json:"directed"`            // only follow relations From -> To
+}
+
+type TraverseRequest struct {
+	Operation string              `json:"operation"`
+	Options   TraverseOptions     `json:"options"`
+	Start     TraverseStart       `json:"start"`
+	Relations []Relation          `json:"relations"`
+	Datasets  types.MultiDatasets `json:"datasets"`
+}
+
+// GraphNode is a value reached by the traversal.
+type GraphNode struct {
+	ID    string `json:"id"`    // type:value
+	Type  string `json:"type"`  // node type
+	Value string `json:"value"` // as it appears in the first row that reached it
+	Depth int    `json:"depth"` // hops from the start node
+}
+
+// GraphEdge links two nodes through the rows of one relation.
+type GraphEdge struct {
+	From     string `json:"from"` // node IDs, in the relation's From -> To direction
+	To       string `json:"to"`
+	Relation string `json:"relation"`
+	Table    string `json:"table"`
+	Rows     []int  `json:"rows"` // 1-based data row numbers
+}
+
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+type TraverseResponse struct {
+	Operation string          `json:"operation"`
+	Summary   map[string]int  `json:"summary"`
+	Nodes     types.TableData `json:"nodes"` // id, type, value, depth
+	Edges     types.TableData `json:"edges"` // from, to, relation, table, count, rows
+	Graph     Graph           `json:"graph"`
+	DOT       string          `json:"dot"` // GraphViz export of Graph
+	Truncated bool            `json:"truncated"`
+	Error     *string         `json:"error"`
+}
+
+// relationIndex is a Relation resolved against its table, with its rows indexed by
+// normalized From and To value.
+type relationIndex struct {
+	Relation
+	rows             [][]string
+	fromIdx, toIdx   int
+	byFrom, byTo     map[string][]int
+	fromType, toType string
+}
+
+// nodeType returns the node type of a relation column.
+func nodeType(explicit, column string) string {
+	if t := strings.TrimSpace(explicit); t != "" {
+		return strings.ToLower(t)
+	}
+	return strings.ToLower(strings.TrimSpace(column))
+}
+
+// traverseTable finds the table a relation refers to. Lists win over the master,
+// so a list named "master" (e.g. from master.csv) is not shadowed by an empty one.
+func traverseTable(ds types.MultiDatasets, name string) (types.TableData, bool) {
+	for _, l := range ds.Lists {
+		if l.Name == name {
+			return l.Table, true
+		}
+	}
+	if name == "master" {
+		return ds.Master, true
+	}
+	return types.TableData{}, false
+}
+
+// indexRelation resolves r and indexes the rows of its table.
+func indexRelation(ds types.MultiDatasets, r Relation, normalize func(string) string) (*relationIndex, error) {
+	tbl, ok := traverseTable(ds, r.Table)
+	if !ok {
+		return nil, fmt.Errorf("unknown table '%s'", r.Table)
+	}
+	fromIdx, err := utils.ResolveKeyIndex(tbl, r.From)
+	if err != nil {
+		return nil, fmt.Errorf("from column '%s': %w", r.From, err)
+	}
+	toIdx, err := utils.ResolveKeyIndex(tbl, r.To)
+	if err != nil {
+		return nil, fmt.Errorf("to column '%s': %w", r.To, err)
+	}
+	ri := &relationIndex{
+		Relation: r,
+		rows:     tbl.Rows,
+		fromIdx:  fromIdx,
+		toIdx:    toIdx,
+		byFrom:   map[string][]int{},
+		byTo:     map[string][]int{},
+		fromType: nodeType(r.FromType, r.From),
+		toType:   nodeType(r.ToType, r.To),
+	}
+	if ri.Label == "" {
+		ri.Label = r.Table
+	}
+	for i, row := range tbl.Rows {
+		if k := normalize(cellAt(row, fromIdx)); k != "" {
+			ri.byFrom[k] = append(ri.byFrom[k], i)
+		}
+		if k := normalize(cellAt(row, toIdx)); k != "" {
+			ri.byTo[k] = append(ri.byTo[k], i)
+		}
+	}
+	return ri, nil
+}
+
+// graphBuilder collects nodes and edges, deduplicating them by key.
+type graphBuilder struct {
+	maxNodes  int
+	truncated bool
+	nodes     []GraphNode
+	nodeKeys  []string // normalized value per node
+	byNode    map[string]int
+	edges     []GraphEdge
+	byEdge    map[string]int
+	edgeRows  map[string]bool // edge key + row number, as edges are seen from both ends
+}
+
+// node returns the index of the node (type, normalized key), adding it at depth
+// when new; -1 when the node limit is reached.
+func (g *graphBuilder) node(typ, key, value string, depth int) int {
+	k := typ + utils.KeySeparator + key
+	if i, ok := g.byNode[k]; ok {
+		return i
+	}
+	if len(g.nodes) >= g.maxNodes {
+		g.truncated = true
+		return -1
+	}
+	g.byNode[k] = len(g.nodes)
+	g.nodes = append(g.nodes, GraphNode{ID: typ + ":" + value, Type: typ, Value: value, Depth: depth})
+	g.nodeKeys = append(g.nodeKeys, key)
+	return len(g.nodes) - 1
+}
+
+// edge records that row rowNum of r links node from to node to. Relations over
+// different columns of one table stay separate edges even with the same label.
+func (g *graphBuilder) edge(r *relationIndex, from, to, rowNum int) {
+	k := strconv.Itoa(from) + ">" + strconv.Itoa(to) + ">" + r.Label + ">" + r.Table +
+		">" + strconv.Itoa(r.fromIdx) + ">" + strconv.Itoa(r.toIdx)
+	i, ok := g.byEdge[k]
+	if !ok {
+		i = len(g.edges)
+		g.byEdge[k] = i
+		g.edges = append(g.edges, GraphEdge{From: g.nodes[from].ID, To: g.nodes[to].ID, Relation: r.Label, Table: r.Table})
+	}
+	if rk := k + ">" + strconv.Itoa(rowNum); !g.edgeRows[rk] {
+		g.edgeRows[rk] = true
+		g.edges[i].Rows = append(g.edges[i].Rows, rowNum)
+	}
+}
+
+// Traverse walks the relations breadth-first from the start value up to max_depth
+// hops and returns the nodes and edges reached.
+func Traverse(req TraverseRequest) (TraverseResponse, error) {
+	var res TraverseResponse
+	res.Operation = req.Operation
+	start := time.Now()
+
+	opts := req.Options
+	if len(req.Relations) == 0 {
+		msg := "relations required"
+		res.Error = &msg
+		return res, errors.New(msg)
+	}
+	if strings.TrimSpace(req.Start.Type) == "" || strings.TrimSpace(req.Start.Value) == "" {
+		msg := "start.type and start.value are required"
+		res.Error = &msg
+		return res, errors.New(msg)
+	}
+	if opts.MaxDepth < 0 || opts.MaxNodes < 0 {
+		msg := "max_depth and max_nodes must not be negative"
+		res.Error = &msg
+		return res, errors.New(msg)
+	}
+	maxDepth := opts.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultTraverseDepth
+	}
+	maxNodes := opts.MaxNodes
+	if maxNodes == 0 {
+		maxNodes = DefaultTraverseMaxNodes
+	}
+	normalize, err := newKeyNormalizer(opts.Normalizers, opts.TrimSpaces, opts.MatchMethod == MatchCaseInsensitive)
+	if err != nil {
+		msg := err.Error()
+		res.Error = &msg
+		return res, err
+	}
+
+	rels := make([]*relationIndex, 0, len(req.Relations))
+	nodeTypes := map[string]bool{}
+	for i, r := range req.Relations {
+		ri, err := indexRelation(req.Datasets, r, normalize)
+		if err != nil {
+			msg := fmt.Sprintf("relation %d: %s", i+1, err.Error())
+			res.Error = &msg
+			return res, errors.New(msg)
+		}
+		rels = append(rels, ri)
+		nodeTypes[ri.fromType] = true
+		nodeTypes[ri.toType] = true
+	}
+	startType := nodeType(req.Start.Type, "")
+	if !nodeTypes[startType] {
+		msg := fmt.Sprintf("start type '%s' is not a node type of any relation", req.Start.Type)
+		res.Error = &msg
+		return res, errors.New(msg)
+	}
+
+	g := &graphBuilder{maxNodes: maxNodes, byNode: map[string]int{}, byEdge: map[string]int{}, edgeRows: map[string]bool{}}
+	g.node(startType, normalize(req.Start.Value), req.Start.Value, 0)
+	depthReached := 0
+	for queue := []int{0}; len(queue) > 0; queue = queue[1:] {
+		n := queue[0]
+		node := g.nodes[n]
+		if node.Depth >= maxDepth {
+			continue
+		}
+		// follow links to the other end of every relation row holding the node's value
+		follow := func(r *relationIndex, rowIdxs []int, otherIdx int, otherType string, outgoing bool) {
+			for _, i := range rowIdxs {
+				value := cellAt(r.rows[i], otherIdx)
+				key := normalize(value)
+				if key == "" {
+					continue
+				}
+				before := len(g.nodes)
+				m := g.node(otherType, key, value, node.Depth+1)
+				if m < 0 || m == n {
+					continue
+				}
+				if len(g.nodes) > before {
+					queue = append(queue, m)
+					depthReached = node.Depth + 1
+				}
+				if outgoing {
+					g.edge(r, n, m, i+1)
+				} else {
+					g.edge(r, m, n, i+1)
+				}
+			}
+		}
+		key := g.nodeKeys[n]
+		for _, r := range rels {
+			if r.fromType == node.Type {
+				follow(r, r.byFrom[key], r.toIdx, r.toType, true)
+			}
+			if r.toType == node.Type && !opts.Directed {
+				follow(r, r.byTo[key], r.fromIdx, r.fromType, false)
+			}
+		}
+	}
+
+	res.Graph = Graph{Nodes: g.nodes, Edges: g.edges}
+	if res.Graph.Edges == nil {
+		res.Graph.Edges = []GraphEdge{}
+	}
+	res.Nodes = types.TableData{HasHeader: true, Header: []string{"id", "type", "value", "depth"}, Rows: [][]string{}}
+	for _, nd := range g.nodes {
+		res.Nodes.Rows = append(res.Nodes.Rows, []string{nd.ID, nd.Type, nd.Value, strconv.Itoa(nd.Depth)})
+	}
+	res.Edges = types.TableData{HasHeader: true, Header: []string{"from", "to", "relation", "table", "count", "rows"}, Rows: [][]string{}}
+	for _, e := range res.Graph.Edges {
+		sort.Ints(e.Rows)
+		res.Edges.Rows = append(res.Edges.Rows, []string{e.From, e.To, e.Relation, e.Table, strconv.Itoa(len(e.Rows)), joinInts(e.Rows, " ")})
+	}
+	res.DOT = res.Graph.DOT()
+	res.Truncated = g.truncated
+	res.Summary = map[string]int{
+		"nodes":       len(g.nodes),
+		"edges":       len(g.edges),
+		"depth":       depthReached,
+		"relations":   len(rels),
+		"duration_ms": int(time.Since(start).Milliseconds()),
+	}
+	res.Error = nil
+	return res, nil
+}
+
+// DOT renders the graph in GraphViz DOT format; the start node is drawn bold.
+func (g Graph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph links {\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, n := range g.Nodes {
+		style := ""
+		if n.Depth == 0 {
+			style = ", style=bold"
+		}
+		fmt.Fprintf(&b, "  %s [label=%s%s];\n", dotQuote(n.ID), dotQuote(n.Type+"\n"+n.Value), style)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s];\n", dotQuote(e.From), dotQuote(e.To), dotQuote(e.Relation))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotQuote returns s as a DOT double-quoted string.
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
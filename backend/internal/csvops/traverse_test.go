@@ -0,0 +1,65 @@
+package csvops
+
+import (
+	"testing"
+
+	"github.com/JustUsingaWebsite/csv-powerops/backend/internal/types"
+)
+
+func TestTraverse(t *testing.T) {
+	assets := types.TableData{
+		HasHeader: true,
+		Header:    []string{"device", "user", "backup_user"},
+		Rows: [][]string{
+			{"pc1", "alice", "alice"},
+			{"pc2", "bob", "alice"},
+		},
+	}
+	tests := []struct {
+		name      string
+		datasets  types.MultiDatasets
+		relations []Relation
+		wantNodes int
+		wantEdges int
+	}{
+		{
+			// the CLI loads master.csv as a list named "master"
+			"list named master",
+			types.MultiDatasets{Lists: []types.NamedTable{{Name: "master", Table: assets}}},
+			[]Relation{{Table: "master", From: "device", To: "user"}},
+			2, 1,
+		},
+		{
+			"master table",
+			types.MultiDatasets{Master: assets},
+			[]Relation{{Table: "master", From: "device", To: "user"}},
+			2, 1,
+		},
+		{
+			// pc1 -> alice through both columns of row 1: two edges, not one
+			"relations on different columns",
+			types.MultiDatasets{Lists: []types.NamedTable{{Name: "assets", Table: assets}}},
+			[]Relation{
+				{Table: "assets", From: "device", To: "user"},
+				{Table: "assets", From: "device", To: "backup_user", ToType: "user"},
+			},
+			3, 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Traverse(TraverseRequest{
+				Options:   TraverseOptions{MaxDepth: 2},
+				Start:     TraverseStart{Type: "device", Value: "pc1"},
+				Relations: tt.relations,
+				Datasets:  tt.datasets,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Graph.Nodes) != tt.wantNodes || len(res.Graph.Edges) != tt.wantEdges {
+				t.Errorf("got %d nodes, %d edges, want %d, %d: %v", len(res.Graph.Nodes), len(res.Graph.Edges), tt.wantNodes, tt.wantEdges, res.Edges.Rows)
+			}
+		})
+	}
+}
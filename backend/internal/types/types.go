@@ -23,9 +23,14 @@ type OpOptions struct {
 }
 
 type NamedTable struct {
-	Name    string    `json:"name"`
-	Table   TableData `json:"table"`
-	ListKey string    `json:"list_key,omitempty"`
+	Name     string    `json:"name"`
+	Table    TableData `json:"table"`
+	ListKey  string    `json:"list_key,omitempty"`
+	ListKeys []string  `json:"list_keys,omitempty"` // composite key columns, in the order of the master's; overrides ListKey
+
+	// ColumnMap maps list columns onto master columns (header name or numeric index
+	// on both sides), e.g. {"hostname": "DeviceName"}; used by one_to_many's combined output.
+	ColumnMap map[string]string `json:"column_map,omitempty"`
 }
 
 // MultiDatasets groups master + many lists.
@@ -33,3 +38,21 @@ type MultiDatasets struct {
 	Master TableData    `json:"master"`
 	Lists  []NamedTable `json:"lists"`
 }
+
+// RowReader yields rows one at a time. Next returns io.EOF after the last row.
+type RowReader interface {
+	Next() ([]string, error)
+}
+
+// RowWriter receives rows one at a time.
+type RowWriter interface {
+	Write(row []string) error
+}
+
+// RowStream is the streaming counterpart of TableData: the header is known up
+// front and the rows are pulled on demand, so tables larger than memory can be processed.
+type RowStream struct {
+	HasHeader bool
+	Header    []string
+	Rows      RowReader
+}
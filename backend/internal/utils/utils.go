@@ -2,6 +2,7 @@ package utils
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 	"unicode"
@@ -45,6 +46,51 @@ func ResolveKeyIndex(tbl types.TableData, key string) (int, error) {
 	return idx, nil
 }
 
+// ResolveKeyIndices resolves an ordered list of key columns (composite key) with
+// ResolveKeyIndex. Errors name the offending column when there are several.
+func ResolveKeyIndices(tbl types.TableData, keys []string) ([]int, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no key columns")
+	}
+	idxs := make([]int, 0, len(keys))
+	for _, k := range keys {
+		idx, err := ResolveKeyIndex(tbl, k)
+		if err != nil {
+			if len(keys) > 1 {
+				return nil, fmt.Errorf("'%s': %w", k, err)
+			}
+			return nil, err
+		}
+		idxs = append(idxs, idx)
+	}
+	return idxs, nil
+}
+
+// CompositeKey returns the key of row built from the columns idxs, each cell
+// normalized by normalize. Parts are joined with KeySeparator, so ("a b", "c") and
+// ("a", "b c") stay distinct; a single column yields the plain normalized value.
+func CompositeKey(row []string, idxs []int, normalize func(string) string) string {
+	if len(idxs) == 1 {
+		return normalize(cellAt(row, idxs[0]))
+	}
+	parts := make([]string, len(idxs))
+	for i, idx := range idxs {
+		parts[i] = normalize(cellAt(row, idx))
+	}
+	return strings.Join(parts, KeySeparator)
+}
+
+// KeySeparator joins the parts of a composite key.
+const KeySeparator = "\x1f"
+
+// cellAt returns row[idx], or "" when the row is too short.
+func cellAt(row []string, idx int) string {
+	if idx >= 0 && idx < len(row) {
+		return row[idx]
+	}
+	return ""
+}
+
 // Normalize applies trimming and case normalization according to flags.
 func Normalize(val string, trim bool, caseInsensitive bool) string {
 	if trim {
@@ -1,3 +1,5 @@
 module github.com/JustUsingaWebsite/csv-powerops
 
 go 1.24.5
+
+require golang.org/x/text v0.30.0